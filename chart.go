@@ -0,0 +1,214 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// chartColors are the colours of the successive series
+var chartColors = []Color{
+	{66, 133, 244}, {219, 68, 55}, {244, 180, 0}, {15, 157, 88}, {171, 71, 188}, {0, 172, 193},
+}
+
+// chart is a bar or line chart read from the JSON of a ```chart block:
+//
+//	{"type": "bar", "title": "Downloads", "labels": ["Q1", "Q2"],
+//	 "series": [{"name": "2024", "values": [10, 20]}]}
+type chart struct {
+	Type   string
+	Title  string
+	Labels []string
+	Series []struct {
+		Name   string
+		Values []float64
+	}
+
+	style         Styler
+	width, height float64
+	// plot area
+	left, top, right, bottom float64
+	// value axis
+	min, max, step float64
+	decimals       int
+	legend         bool
+}
+
+// renderChart is the built-in renderer of ```chart blocks
+func renderChart(r *PdfRenderer, source string) (Diagram, error) {
+	c := &chart{Type: "bar", style: r.Diagram, width: 400, height: 240}
+	if err := json.Unmarshal([]byte(source), c); err != nil {
+		return nil, err
+	}
+	if c.Type != "bar" && c.Type != "line" {
+		return nil, fmt.Errorf("unknown chart type %q", c.Type)
+	}
+	if len(c.Series) == 0 {
+		return nil, fmt.Errorf("no series")
+	}
+	c.layout(r.Pdf)
+	return c, nil
+}
+
+// count returns the number of points on the category axis
+func (c *chart) count() int {
+	n := len(c.Labels)
+	for _, s := range c.Series {
+		n = max(n, len(s.Values))
+	}
+	return n
+}
+
+func (c *chart) layout(pdf *fpdf.Fpdf) {
+	lo, hi := 0.0, 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		c.legend = c.legend || s.Name != ""
+	}
+	// about five steps of 1, 2 or 5 times a power of ten
+	span := hi - lo
+	if span == 0 {
+		span = 1
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(span/5)))
+	c.step = 10 * mag
+	for _, f := range []float64{1, 2, 5} {
+		if span/(f*mag) <= 6 {
+			c.step = f * mag
+			break
+		}
+	}
+	c.min = math.Floor(lo/c.step) * c.step
+	c.max = math.Ceil(hi/c.step) * c.step
+	if c.max == c.min {
+		c.max = c.min + c.step
+	}
+	c.decimals = max(0, -int(math.Floor(math.Log10(c.step))))
+
+	size := c.style.Size
+	lh := 1.2 * size
+	pdf.SetFont(c.style.Font, "", size)
+	labelWidth := 0.0
+	for v := c.min; v <= c.max+c.step/2; v += c.step {
+		labelWidth = math.Max(labelWidth, pdf.GetStringWidth(c.tick(v)))
+	}
+	c.left = labelWidth + size/2 + 2
+	c.top = size
+	if c.Title != "" {
+		c.top += 1.5 * lh
+	}
+	c.right = c.width - size
+	c.bottom = c.height - 1.5*lh
+	if c.legend {
+		c.bottom -= 1.5 * lh
+	}
+}
+
+func (c *chart) tick(v float64) string {
+	return strconv.FormatFloat(v, 'f', c.decimals, 64)
+}
+
+// y returns the position of value v on the page
+func (c *chart) y(v float64) float64 {
+	return c.bottom - (v-c.min)/(c.max-c.min)*(c.bottom-c.top)
+}
+
+func (c *chart) Size() (w, h float64) {
+	return c.width, c.height
+}
+
+func (c *chart) Draw(pdf *fpdf.Fpdf, x, y float64) {
+	tc := c.style.TextColor
+	size := c.style.Size
+	lh := 1.2 * size
+	pdf.SetDrawColor(tc.Red, tc.Green, tc.Blue)
+	pdf.SetTextColor(tc.Red, tc.Green, tc.Blue)
+	if c.Title != "" {
+		pdf.SetFont(c.style.Font, "b", size)
+		pdf.Text(x+(c.width-pdf.GetStringWidth(c.Title))/2, y+lh+0.3*size, c.Title)
+	}
+	pdf.SetFont(c.style.Font, "", size)
+
+	// value axis, with a grid line at each step
+	for v := c.min; v <= c.max+c.step/2; v += c.step {
+		ty := y + c.y(v)
+		pdf.SetLineWidth(0.25)
+		pdf.SetDashPattern([]float64{1, 2}, 0)
+		pdf.Line(x+c.left, ty, x+c.right, ty)
+		pdf.SetDashPattern([]float64{}, 0)
+		label := c.tick(v)
+		pdf.Text(x+c.left-size/2-pdf.GetStringWidth(label), ty+0.3*size, label)
+	}
+	pdf.SetLineWidth(0.75)
+	pdf.Line(x+c.left, y+c.top, x+c.left, y+c.bottom)
+	base := y + c.y(math.Max(c.min, 0))
+	pdf.Line(x+c.left, base, x+c.right, base)
+
+	// categories
+	n := c.count()
+	slot := (c.right - c.left) / float64(n)
+	for i := 0; i < n && i < len(c.Labels); i++ {
+		label := c.Labels[i]
+		pdf.Text(x+c.left+(float64(i)+0.5)*slot-pdf.GetStringWidth(label)/2, y+c.bottom+lh, label)
+	}
+
+	for k, s := range c.Series {
+		col := chartColors[k%len(chartColors)]
+		pdf.SetFillColor(col.Red, col.Green, col.Blue)
+		pdf.SetDrawColor(col.Red, col.Green, col.Blue)
+		if c.Type == "bar" {
+			bar := 0.7 * slot / float64(len(c.Series))
+			for i, v := range s.Values {
+				bx := x + c.left + float64(i)*slot + 0.15*slot + float64(k)*bar
+				top, bottom := y+c.y(v), base
+				if top > bottom {
+					top, bottom = bottom, top
+				}
+				pdf.Rect(bx, top, bar, bottom-top, "F")
+			}
+			continue
+		}
+		pdf.SetLineWidth(1.5)
+		for i, v := range s.Values {
+			px, py := x+c.left+(float64(i)+0.5)*slot, y+c.y(v)
+			if i > 0 {
+				pdf.Line(x+c.left+(float64(i)-0.5)*slot, y+c.y(s.Values[i-1]), px, py)
+			}
+			pdf.Circle(px, py, 2, "F")
+		}
+	}
+
+	if !c.legend {
+		return
+	}
+	// legend, centered under the chart
+	box := 0.8 * size
+	width := 0.0
+	for _, s := range c.Series {
+		width += box + size/2 + pdf.GetStringWidth(s.Name) + 1.5*size
+	}
+	lx := x + (c.width-width+1.5*size)/2
+	ly := y + c.height - lh
+	for k, s := range c.Series {
+		col := chartColors[k%len(chartColors)]
+		pdf.SetFillColor(col.Red, col.Green, col.Blue)
+		pdf.Rect(lx, ly-box/2, box, box, "F")
+		lx += box + size/2
+		pdf.Text(lx, ly+0.3*size, s.Name)
+		lx += pdf.GetStringWidth(s.Name) + 1.5*size
+	}
+}
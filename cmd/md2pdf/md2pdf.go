@@ -11,7 +11,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/solworktech/md2pdf/v2"
@@ -29,10 +31,24 @@ var fontName = flag.String("font-name", "", "Font name ID; e.g 'Helvetica-1251'"
 var themeArg = flag.String("theme", "light", "[light | dark | /path/to/custom/theme.json]")
 var hrAsNewPage = flag.Bool("new-page-on-hr", false, "Interpret HR as a new page; useful for presentations")
 var printFooter = flag.Bool("with-footer", false, "Print doc footer (<author>  <title>  <page number>)")
+var headerArg = flag.String("header", "", "Page header, as 'left|center|right' text which may hold {page}, {pages}, {title}, {author}, {date} and {section}")
+var footerArg = flag.String("footer", "", "Page footer, as 'left|center|right' text (see -header); replaces the -with-footer one")
+var endnotes = flag.Bool("endnotes", false, "Render footnotes at the end of the document instead of at the bottom of each page")
+var mmark = flag.Bool("mmark", false, "Enable Mmark syntax, e.g. {frontmatter}, {mainmatter} and {backmatter}")
+var mathNumbering = flag.Bool("math-numbering", false, "Number display math equations")
 var generateTOC = flag.Bool("generate-toc", false, "Auto Generate Table of Contents (TOC)")
+var tocDepth = flag.Int("toc-depth", 6, "Deepest heading level listed in the TOC")
+var keepWithNext = flag.Bool("keep-with-next", false, "Move headings left at the bottom of a page to the next one, with the text after them")
+var orphans = flag.Int("orphans", 0, "Least number of lines of a paragraph left at the bottom of a page, e.g. 2")
+var widows = flag.Int("widows", 0, "Least number of lines of a paragraph carried over to the top of a page, e.g. 2")
+var numberHeadings = flag.String("number-headings", "", "Number the headings, one numeral per level: e.g. '1.1.1', 'A.1.a' or 'I.1.i'")
+var numberFrom = flag.Int("number-from", 1, "First heading level numbered with -number-headings; e.g. 2 to leave H1 titles out")
+var outlineDepth = flag.Int("outline-depth", 6, "Deepest heading level bookmarked in the PDF outline; 0 for none")
 var pageSize = flag.String("page-size", "A4", "[A3 | A4 | A5]")
 var orientation = flag.String("orientation", "portrait", "[portrait | landscape]")
 var logFile = flag.String("log-file", "", "Path to log file")
+var reproducible = flag.Bool("reproducible", false, "Produce byte-identical output for identical input; the date is taken from SOURCE_DATE_EPOCH if set")
+var strict = flag.Bool("strict", false, "Fail on rendering errors (missing images, unsupported elements) instead of warning")
 var help = flag.Bool("help", false, "Show usage message")
 var ver = flag.Bool("version", false, "Print version and build info")
 var version = "dev"
@@ -42,6 +58,11 @@ var _, fileName, fileLine, ok = runtime.Caller(0)
 
 var opts []mdtopdf.RenderOption
 
+// frontMatter is that of the (first) input file, if any, and
+// frontMatterDir the directory of that file, "" if not a local one
+var frontMatter *mdtopdf.FrontMatter
+var frontMatterDir string
+
 func processRemoteInputFile(url string) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -67,6 +88,85 @@ func glob(dir string, validExts []string) ([]string, error) {
 	return files, err
 }
 
+// stripFrontMatter removes the front matter from content, keeping the
+// first one found; dir is the directory of the file content was read from
+func stripFrontMatter(content []byte, dir string) []byte {
+	fm, body, err := mdtopdf.ParseFrontMatter(content)
+	if err != nil {
+		log.Printf("warning: %v; front matter ignored\n", err)
+		return content
+	}
+	if frontMatter == nil {
+		frontMatter, frontMatterDir = fm, dir
+	}
+	return body
+}
+
+// applyFrontMatter sets the flags not given on the command line from
+// the front matter
+func applyFrontMatter(fm *mdtopdf.FrontMatter) {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	values := map[string]string{
+		"title":        fm.Title,
+		"author":       fm.Author,
+		"page-size":    fm.PaperSize,
+		"orientation":  fm.Orientation,
+		"generate-toc": strconv.FormatBool(fm.TOC),
+		"with-footer":  strconv.FormatBool(fm.Footer),
+	}
+	// the theme file named by a document must be next to it
+	if fm.Theme != "" {
+		theme, file, err := fm.ResolveTheme(frontMatterDir)
+		switch {
+		case err != nil:
+			log.Printf("warning: %v; theme ignored\n", err)
+		case theme == mdtopdf.DARK:
+			values["theme"] = "dark"
+		case theme == mdtopdf.CUSTOM:
+			values["theme"] = file
+		default:
+			values["theme"] = "light"
+		}
+	}
+	for name, value := range values {
+		if value != "" && !set[name] {
+			if err := flag.Set(name, value); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// headingNumbering reads a '1.a.i' heading numbering
+func headingNumbering(arg string, from int) mdtopdf.NumberingOptions {
+	styles := map[string]mdtopdf.NumberStyle{
+		"1": mdtopdf.NumberDecimal,
+		"A": mdtopdf.NumberUpperAlpha,
+		"a": mdtopdf.NumberLowerAlpha,
+		"I": mdtopdf.NumberUpperRoman,
+		"i": mdtopdf.NumberLowerRoman,
+	}
+	numbering := mdtopdf.NumberingOptions{StartLevel: from}
+	for _, s := range strings.Split(arg, ".") {
+		style, ok := styles[s]
+		if !ok {
+			log.Fatalf("Unknown heading numeral %q in -number-headings; use 1, A, a, I or i", s)
+		}
+		numbering.Styles = append(numbering.Styles, style)
+	}
+	numbering.MaxLevel = min(from+len(numbering.Styles)-1, 6)
+	return numbering
+}
+
+// pageTemplate reads a 'left|center|right' header or footer
+func pageTemplate(arg string) mdtopdf.PageTemplate {
+	slots := append(strings.SplitN(arg, "|", 3), "", "")
+	return mdtopdf.PageTemplate{Left: slots[0], Center: slots[1], Right: slots[2]}
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	flag.Parse()
@@ -89,6 +189,39 @@ func main() {
 		opts = append(opts, mdtopdf.IsHorizontalRuleNewPage(true))
 	}
 
+	if *endnotes {
+		opts = append(opts, mdtopdf.WithFootnoteMode(mdtopdf.FootnotesEndnotes))
+	}
+
+	if *strict {
+		opts = append(opts, mdtopdf.WithStrictErrors(true))
+	}
+
+	if *mathNumbering {
+		opts = append(opts, mdtopdf.WithMathNumbering(true))
+	}
+
+	// SOURCE_DATE_EPOCH, see https://reproducible-builds.org/specs/source-date-epoch/
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); *reproducible || epoch != "" {
+		var date time.Time
+		if epoch != "" {
+			seconds, err := strconv.ParseInt(epoch, 10, 64)
+			if err != nil {
+				log.Fatalf("SOURCE_DATE_EPOCH: %v", err)
+			}
+			date = time.Unix(seconds, 0).UTC()
+		}
+		opts = append(opts, mdtopdf.WithReproducible(date))
+	}
+
+	if *keepWithNext || *orphans > 1 || *widows > 1 {
+		opts = append(opts, mdtopdf.WithPagination(mdtopdf.PaginationOptions{KeepWithNext: *keepWithNext, Orphans: *orphans, Widows: *widows}))
+	}
+
+	if *outlineDepth != 6 {
+		opts = append(opts, mdtopdf.WithOutline(mdtopdf.OutlineOptions{MaxLevel: *outlineDepth}))
+	}
+
 	if *unicodeSupport != "" {
 		opts = append(opts, mdtopdf.WithUnicodeTranslator(*unicodeSupport))
 	}
@@ -112,6 +245,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		content = stripFrontMatter(content, "")
 	} else {
 		httpRegex := regexp.MustCompile("^http(s)?://")
 		if httpRegex.Match([]byte(*input)) {
@@ -119,6 +253,7 @@ func main() {
 			if err != nil {
 				log.Fatal(err)
 			}
+			content = stripFrontMatter(content, "")
 			// get the base URL so we can adjust relative links and images
 			inputBaseURL = strings.Replace(filepath.Dir(*input), ":/", "://", 1)
 		} else {
@@ -139,7 +274,7 @@ func main() {
 					if err != nil {
 						log.Fatal(err)
 					}
-					content = append(content, fileContents...)
+					content = append(content, stripFrontMatter(fileContents, filepath.Dir(filePath))...)
 					if i < len(files)-1 {
 						content = append(content, []byte("---\n")...)
 					}
@@ -149,10 +284,33 @@ func main() {
 				if err != nil {
 					log.Fatal(err)
 				}
+				content = stripFrontMatter(content, filepath.Dir(*input))
 			}
 		}
 	}
 
+	if frontMatter != nil {
+		applyFrontMatter(frontMatter)
+	}
+
+	if *generateTOC {
+		opts = append(opts, mdtopdf.WithTableOfContents(mdtopdf.TOCOptions{MaxLevel: *tocDepth}))
+	}
+
+	if *numberHeadings != "" {
+		opts = append(opts, mdtopdf.WithHeadingNumbering(headingNumbering(*numberHeadings, *numberFrom)))
+	}
+
+	if *headerArg != "" {
+		opts = append(opts, mdtopdf.WithHeader(pageTemplate(*headerArg)))
+	}
+
+	if *footerArg != "" {
+		opts = append(opts, mdtopdf.WithFooter(pageTemplate(*footerArg)))
+	} else if *printFooter {
+		opts = append(opts, mdtopdf.WithFooter(mdtopdf.PageTemplate{Left: "{author}", Center: "{title}", Right: "Page {page}"}))
+	}
+
 	theme := mdtopdf.LIGHT
 	themeFile := ""
 	if *themeArg == "dark" {
@@ -172,53 +330,30 @@ func main() {
 		CustomThemeFile: themeFile,
 		FontFile:        *fontFile,
 		FontName:        *fontName,
+		Metadata: mdtopdf.Metadata{
+			Title:    *title,
+			Author:   *author,
+			Subject:  *title,
+			Creator:  "md2pdf " + version,
+			Producer: "md2pdf " + version,
+		},
 	}
-
-	pf := mdtopdf.NewPdfRenderer(params)
-
-	if *generateTOC == true {
-		headers, err := mdtopdf.GetTOCEntries(content)
-		if err != nil {
-			log.Fatal(err)
-		}
-		headerLinks := make(map[string]*int)
-		for _, header := range headers {
-			linkID := pf.Pdf.AddLink()
-			headerLinks[header.Title] = &linkID
-
-			// debug
-			// log.Printf("Header: '%s' (Level %d) -> Link ID: %d\n",
-			// header.Title, header.Level, linkID)
-		}
-
-		pf.SetTOCLinks(headerLinks)
-		pf.Pdf.SetFont("Arial", "B", 24)
-
-		// Add a table of contents with clickable links
-		pf.Pdf.Cell(40, 10, "Table of Contents")
-		pf.Pdf.Ln(30)
-
-		for _, header := range headers {
-			if linkPtr, exists := headerLinks[header.Title]; exists {
-				link := *linkPtr
-				pf.Pdf.SetFont("Arial", "", 12)
-				pf.Pdf.SetTextColor(100, 149, 237)
-				tr := pf.Pdf.UnicodeTranslatorFromDescriptor("")
-				bulletChar := tr("•")
-				indent := strings.Repeat("  ", header.Level-1)
-				pf.Pdf.WriteLinkID(8, fmt.Sprintf("%s %s %s", indent, bulletChar, header.Title), link)
-				pf.Pdf.Ln(15)
-			}
+	if frontMatter != nil {
+		if frontMatter.Subject != "" {
+			params.Metadata.Subject = frontMatter.Subject
 		}
-		pf.Pdf.AddPage()
+		params.Metadata.Keywords = frontMatter.Keywords
 	}
 
+	pf := mdtopdf.NewPdfRenderer(params)
+
 	if inputBaseURL != "" {
 		pf.InputBaseURL = inputBaseURL
 	}
-	pf.Pdf.SetSubject(*title, true)
-	pf.Pdf.SetTitle(*title, true)
-	pf.Extensions = parser.NoIntraEmphasis | parser.Tables | parser.FencedCode | parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists
+	pf.Extensions = parser.NoIntraEmphasis | parser.Tables | parser.FencedCode | parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs | parser.AutoHeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists | parser.Footnotes | parser.MathJax
+	if *mmark {
+		pf.Extensions |= parser.Mmark
+	}
 
 	if *fontFile != "" && *fontName != "" {
 		fmt.Println(*fontFile)
@@ -234,33 +369,12 @@ func main() {
 
 	}
 
-	if *printFooter {
-		pf.Pdf.SetFooterFunc(func() {
-			pf.Pdf.SetFillColor(pf.BackgroundColor.Red, pf.BackgroundColor.Green, pf.BackgroundColor.Blue)
-			// Position at 1.5 cm from bottom
-			pf.Pdf.SetY(-15)
-			// Arial italic 8
-			pf.Pdf.SetFont("Arial", "I", 8)
-			// Text color in gray
-			pf.Pdf.SetTextColor(128, 128, 128)
-			w, h, _ := pf.Pdf.PageSize(pf.Pdf.PageNo())
-			// fmt.Printf("Width: %f, height: %f, unit: %s\n", w, h, u)
-			pf.Pdf.SetX(4)
-			pf.Pdf.CellFormat(0, 10, fmt.Sprintf("%s", *author), "", 0, "", true, 0, "")
-			middle := w / 2
-			if *orientation == "landscape" {
-				middle = h / 2
-			}
-			pf.Pdf.SetX(middle - float64(len(*title)))
-			pf.Pdf.CellFormat(0, 10, fmt.Sprintf("%s", *title), "", 0, "", true, 0, "")
-			pf.Pdf.SetX(-40)
-			pf.Pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pf.Pdf.PageNo()), "", 0, "", true, 0, "")
-		})
-	}
-
 	err = pf.Process(content)
+	for _, w := range pf.Warnings() {
+		log.Printf("warning: %v\n", w)
+	}
 	if err != nil {
-		fmt.Printf("error: %v\n", err)
+		log.Fatal(err)
 	}
 }
 
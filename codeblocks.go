@@ -0,0 +1,114 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// CodeBlockHandler renders a fenced code block in place of the code
+// listing. info is the whole info string, the language followed by any
+// attributes. It returns false to have the block printed as code (syntax
+// highlighted if possible), reporting the reason with Fail if need be.
+type CodeBlockHandler func(r *PdfRenderer, info, source string) bool
+
+// defaultCodeBlockHandlers are the built-in handlers, keyed by language
+func defaultCodeBlockHandlers() map[string]CodeBlockHandler {
+	return map[string]CodeBlockHandler{
+		"mermaid": diagramHandler(renderMermaid),
+		"dot":     diagramHandler(renderDot),
+		"chart":   diagramHandler(renderChart),
+		"csv":     csvHandler(','),
+		"tsv":     csvHandler('\t'),
+	}
+}
+
+// codeBlockLanguage returns the first word of a code block info string
+func codeBlockLanguage(info string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+	return lang
+}
+
+// csvHandler renders comma (or tab) separated values as a table, the
+// first record being the header; columns of numbers are right aligned
+func csvHandler(comma rune) CodeBlockHandler {
+	return func(r *PdfRenderer, info, source string) bool {
+		cr := csv.NewReader(strings.NewReader(source))
+		cr.Comma = comma
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		cr.LazyQuotes = true
+		records, err := cr.ReadAll()
+		if err == nil && len(records) == 0 {
+			err = fmt.Errorf("no records")
+		}
+		if err != nil {
+			r.fail(&CodeBlockError{Info: codeBlockLanguage(info), Err: err})
+			return false
+		}
+		table := csvTable(records)
+		setColumnWidths(table, r)
+		ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(nil, node, entering)
+		})
+		// the table is drawn once and for all
+		delete(r.ColumnWidths, table)
+		delete(r.columnMinWidths, table)
+		return true
+	}
+}
+
+// csvTable builds the syntax tree of a table holding records
+func csvTable(records [][]string) *ast.Table {
+	columns := 0
+	for _, rec := range records {
+		columns = max(columns, len(rec))
+	}
+	align := make([]ast.CellAlignFlags, columns)
+	for col := range align {
+		numeric := len(records) > 1
+		for _, rec := range records[1:] {
+			if col < len(rec) && strings.TrimSpace(rec[col]) != "" {
+				if _, err := strconv.ParseFloat(strings.TrimSpace(rec[col]), 64); err != nil {
+					numeric = false
+				}
+			}
+		}
+		if numeric {
+			align[col] = ast.TableAlignmentRight
+		}
+	}
+
+	table := &ast.Table{}
+	header, body := &ast.TableHeader{}, &ast.TableBody{}
+	ast.AppendChild(table, header)
+	ast.AppendChild(table, body)
+	for i, rec := range records {
+		row := &ast.TableRow{}
+		if i == 0 {
+			ast.AppendChild(header, row)
+		} else {
+			ast.AppendChild(body, row)
+		}
+		for col := 0; col < columns; col++ {
+			cell := &ast.TableCell{IsHeader: i == 0, Align: align[col]}
+			if col < len(rec) {
+				ast.AppendChild(cell, &ast.Text{Leaf: ast.Leaf{Literal: []byte(strings.TrimSpace(rec[col]))}})
+			}
+			ast.AppendChild(row, cell)
+		}
+	}
+	return table
+}
@@ -28,13 +28,29 @@ const (
 	definition
 )
 
-// This slice of float64 contains the width of each cell
-// in the header of a table. These will be the widths used
-// in the table body as well.
-var cellwidths []float64
-var curdatacell int
-var fill = false
-var incell = false
+// tableState keeps track of the table currently being rendered;
+// only one table at a time!
+type tableState struct {
+	// This slice of float64 contains the width of each cell
+	// in the header of a table. These will be the widths used
+	// in the table body as well.
+	cellwidths  []float64
+	curdatacell int
+	fill        bool
+	incell      bool
+
+	// left edge of the table
+	left float64
+	// styled runs of the cell being read
+	runs []tableRun
+	// cells of the row being laid out
+	row []tableCell
+	// header rows, repeated at the top of each page the table spans
+	header [][]tableCell
+	// header rows are not drawn until the first body row is known,
+	// so they are never left alone at the bottom of a page
+	headerPending bool
+}
 
 func (n listType) String() string {
 	switch n {
@@ -64,10 +80,6 @@ type containerState struct {
 
 	// populated if table cell
 	isHeader bool
-
-	// populated if table cell (apply styles first)
-	cellInnerString      string
-	cellInnerStringStyle *Styler
 }
 
 type states struct {
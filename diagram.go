@@ -0,0 +1,131 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	// register the decoders ImageDiagram accepts
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// Diagram is a diagram made out of a fenced code block, ready to be
+// placed on the page
+type Diagram interface {
+	// Size returns the width and height of the diagram, in points
+	Size() (w, h float64)
+	// Draw draws the diagram with its top left corner at x, y
+	Draw(pdf *fpdf.Fpdf, x, y float64)
+}
+
+// DiagramRenderer turns the source of a fenced code block into a Diagram.
+// When it returns an error, the block is printed as a code listing instead.
+type DiagramRenderer func(r *PdfRenderer, source string) (Diagram, error)
+
+// imageDiagram is a diagram rendered to an image by an external tool
+type imageDiagram struct {
+	name          string
+	data          []byte
+	imageType     string
+	width, height float64
+}
+
+// ImageDiagram wraps PNG, JPEG or GIF data as a Diagram; renderers which
+// shell out to external tools (mermaid-cli, graphviz) can return it.
+// The image is placed at 72 dpi.
+func ImageDiagram(data []byte) (Diagram, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &imageDiagram{
+		name:      fmt.Sprintf("diagram-%x", sha1.Sum(data)),
+		data:      data,
+		imageType: format,
+		width:     float64(cfg.Width),
+		height:    float64(cfg.Height),
+	}, nil
+}
+
+func (d *imageDiagram) Size() (w, h float64) {
+	return d.width, d.height
+}
+
+func (d *imageDiagram) Draw(pdf *fpdf.Fpdf, x, y float64) {
+	opts := fpdf.ImageOptions{ImageType: d.imageType}
+	if pdf.GetImageInfo(d.name) == nil {
+		pdf.RegisterImageOptionsReader(d.name, opts, bytes.NewReader(d.data))
+	}
+	pdf.ImageOptions(d.name, x, y, d.width, d.height, false, opts, 0, "")
+}
+
+// diagramHandler makes a code block handler drawing the diagrams made
+// by render
+func diagramHandler(render DiagramRenderer) CodeBlockHandler {
+	return func(r *PdfRenderer, info, source string) bool {
+		d, err := render(r, source)
+		if err != nil {
+			r.fail(&DiagramError{Info: codeBlockLanguage(info), Err: err})
+			return false
+		}
+		r.drawDiagram(codeBlockLanguage(info), d)
+		return true
+	}
+}
+
+// drawDiagram places d on its own lines, centered and scaled down
+// to fit the page if need be
+func (r *PdfRenderer) drawDiagram(info string, d Diagram) {
+	w, h := d.Size()
+	r.tracer("Diagram", fmt.Sprintf("%v %.1fx%.1f", info, w, h))
+
+	r.cr()
+	pad := 0.5 * r.Normal.Size
+	lm, _, _, _ := r.Pdf.GetMargins()
+	pw, ph := r.Pdf.GetPageSize()
+	_, bm := r.Pdf.GetAutoPageBreak()
+	// shrink diagrams which don't fit the page
+	scale := 1.0
+	if avail := pw - r.mright - lm; w > avail {
+		scale = avail / w
+	}
+	if avail := ph - bm - r.mtop - 2*pad; h*scale > avail {
+		scale = avail / h
+	}
+	w, h = w*scale, h*scale
+	_, y := r.Pdf.GetXY()
+	if y+h+2*pad > ph-bm {
+		r.addPage()
+		_, y = r.Pdf.GetXY()
+	}
+	x := lm + (pw-r.mright-lm-w)/2
+	y += pad
+	if scale < 1 {
+		r.Pdf.TransformBegin()
+		r.Pdf.TransformScale(scale*100, scale*100, x, y)
+		d.Draw(r.Pdf, x, y)
+		r.Pdf.TransformEnd()
+	} else {
+		d.Draw(r.Pdf, x, y)
+	}
+	if err := r.Pdf.Error(); err != nil {
+		// don't leave the whole document in an error state
+		r.Pdf.ClearError()
+		r.fail(&DiagramError{Info: info, Err: err})
+	}
+	r.setStyler(r.Normal)
+	r.Pdf.SetXY(lm, y+h+pad)
+}
@@ -0,0 +1,271 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// dot shapes, with the closest flowchart shape
+var dotShapes = map[string]graphShape{
+	"box": shapeRect, "rect": shapeRect, "rectangle": shapeRect, "square": shapeRect,
+	"record": shapeRect, "Mrecord": shapeRound, "component": shapeRect, "folder": shapeRect,
+	"ellipse": shapeEllipse, "oval": shapeEllipse, "egg": shapeEllipse,
+	"circle": shapeCircle, "doublecircle": shapeCircle, "point": shapeCircle,
+	"diamond": shapeDiamond, "hexagon": shapeHexagon,
+	"plaintext": shapeText, "plain": shapeText, "none": shapeText,
+}
+
+// dotParser reads the subset of the graphviz DOT language describing
+// nodes and edges; subgraphs are flattened and most attributes ignored
+type dotParser struct {
+	tokens   []string
+	pos      int
+	g        *graph
+	directed bool
+}
+
+// renderDot is the built-in renderer of ```dot blocks
+func renderDot(r *PdfRenderer, source string) (Diagram, error) {
+	p := &dotParser{g: newGraph(r, shapeEllipse)}
+	var err error
+	if p.tokens, err = dotTokens(source); err != nil {
+		return nil, err
+	}
+	if p.peek() == "strict" {
+		p.next()
+	}
+	switch p.next() {
+	case "digraph":
+		p.directed = true
+	case "graph":
+	default:
+		return nil, fmt.Errorf("expected graph or digraph")
+	}
+	if p.peek() != "{" {
+		p.next() // graph name
+	}
+	if p.next() != "{" {
+		return nil, fmt.Errorf("expected {")
+	}
+	if err := p.statements(); err != nil {
+		return nil, err
+	}
+	p.g.layout(r.Pdf)
+	return p.g, nil
+}
+
+// dotTokens splits DOT source into identifiers, unquoted strings and
+// punctuation, dropping comments
+func dotTokens(src string) ([]string, error) {
+	var tokens []string
+	s := []rune(src)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == '/' && i+1 < len(s) && s[i+1] == '/':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			for i += 2; i+1 < len(s) && (s[i] != '*' || s[i+1] != '/'); i++ {
+			}
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += 2
+		case c == '"':
+			var b strings.Builder
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					if s[i] == 'n' || s[i] == 'l' || s[i] == 'r' {
+						b.WriteRune('\n')
+						continue
+					}
+				}
+				b.WriteRune(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			// quoted strings are marked so keywords can be told apart
+			tokens = append(tokens, "\x00"+b.String())
+		case c == '-' && i+1 < len(s) && (s[i+1] == '>' || s[i+1] == '-'):
+			tokens = append(tokens, string(s[i:i+2]))
+			i += 2
+		case strings.ContainsRune("{}[]=;,:", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			start := i
+			for i < len(s) && (s[i] == '_' || s[i] == '.' || unicode.IsLetter(s[i]) || unicode.IsDigit(s[i])) {
+				i++
+			}
+			tokens = append(tokens, string(s[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *dotParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *dotParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// dotID returns the value of an identifier or quoted string token
+func dotID(t string) string {
+	return strings.TrimPrefix(t, "\x00")
+}
+
+func isDotID(t string) bool {
+	return t != "" && (t[0] == 0 || !strings.Contains("{}[]=;,:", t) && t != "->" && t != "--")
+}
+
+// statements parses statements up to the closing brace
+func (p *dotParser) statements() error {
+	for {
+		t := p.next()
+		switch {
+		case t == "":
+			return fmt.Errorf("expected }")
+		case t == "}":
+			return nil
+		case t == ";" || t == ",":
+		case t == "subgraph":
+			if p.peek() != "{" {
+				p.next()
+			}
+			if p.next() != "{" {
+				return fmt.Errorf("expected { after subgraph")
+			}
+			if err := p.statements(); err != nil {
+				return err
+			}
+		case t == "{":
+			if err := p.statements(); err != nil {
+				return err
+			}
+		case t == "graph" || t == "node" || t == "edge":
+			attrs, err := p.attributes()
+			if err != nil {
+				return err
+			}
+			if t == "graph" {
+				p.setRankdir(attrs)
+			}
+			if s, ok := dotShapes[attrs["shape"]]; t == "node" && ok {
+				p.g.shape = s
+			}
+		case isDotID(t) && p.peek() == "=":
+			p.next()
+			attrs := map[string]string{dotID(t): dotID(p.next())}
+			p.setRankdir(attrs)
+		case isDotID(t):
+			if err := p.nodeOrEdges(dotID(t)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %q", dotID(t))
+		}
+	}
+}
+
+func (p *dotParser) setRankdir(attrs map[string]string) {
+	switch dir := attrs["rankdir"]; dir {
+	case "TB", "BT", "LR", "RL":
+		p.g.direction = dir
+	}
+}
+
+// attributes parses an optional [name=value, ...] list
+func (p *dotParser) attributes() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.peek() == "[" {
+		p.next()
+		for {
+			t := p.next()
+			if t == "]" {
+				break
+			}
+			if t == "," || t == ";" {
+				continue
+			}
+			if !isDotID(t) || p.next() != "=" {
+				return nil, fmt.Errorf("bad attribute list")
+			}
+			attrs[dotID(t)] = dotID(p.next())
+		}
+	}
+	return attrs, nil
+}
+
+// nodeOrEdges parses a node statement, or a chain of edges starting at id
+func (p *dotParser) nodeOrEdges(id string) error {
+	p.skipPort()
+	chain := []string{id}
+	for p.peek() == "->" || p.peek() == "--" {
+		p.next()
+		t := p.next()
+		if !isDotID(t) {
+			return fmt.Errorf("expected a node after an edge operator")
+		}
+		chain = append(chain, dotID(t))
+		p.skipPort()
+	}
+	attrs, err := p.attributes()
+	if err != nil {
+		return err
+	}
+	if len(chain) == 1 {
+		n := p.g.node(id)
+		if l, ok := attrs["label"]; ok {
+			n.setLabel(l)
+		}
+		if s, ok := dotShapes[attrs["shape"]]; ok {
+			n.shape = s
+		}
+		return nil
+	}
+	for k := 1; k < len(chain); k++ {
+		e := p.g.addEdge(chain[k-1], chain[k])
+		e.label = attrs["label"]
+		e.dashed = attrs["style"] == "dashed" || attrs["style"] == "dotted"
+		e.thick = attrs["style"] == "bold"
+		e.arrow = p.directed && attrs["dir"] != "none"
+		if attrs["dir"] == "back" {
+			e.from, e.to = e.to, e.from
+		}
+	}
+	return nil
+}
+
+// skipPort skips a node port, as in node:port
+func (p *dotParser) skipPort() {
+	for p.peek() == ":" {
+		p.next()
+		p.next()
+	}
+}
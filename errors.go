@@ -0,0 +1,141 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// ThemeError is returned when a custom theme file cannot be read or parsed.
+// Offset is the byte offset in the JSON file at which parsing failed
+// (0 if the error is not a JSON syntax or type error).
+type ThemeError struct {
+	File   string
+	Offset int64
+	Err    error
+}
+
+func (e *ThemeError) Error() string {
+	if e.Offset > 0 {
+		return fmt.Sprintf("theme %v: offset %d: %v", e.File, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("theme %v: %v", e.File, e.Err)
+}
+
+func (e *ThemeError) Unwrap() error {
+	return e.Err
+}
+
+// ImageError is returned when an image cannot be downloaded, detected or converted.
+type ImageError struct {
+	Destination string
+	Err         error
+}
+
+func (e *ImageError) Error() string {
+	return fmt.Sprintf("image %v: %v", e.Destination, e.Err)
+}
+
+func (e *ImageError) Unwrap() error {
+	return e.Err
+}
+
+// MathError is returned when a math expression uses LaTeX the typesetter
+// does not understand; what could be parsed is still rendered.
+type MathError struct {
+	TeX string
+	Err error
+}
+
+func (e *MathError) Error() string {
+	return fmt.Sprintf("math %q: %v", e.TeX, e.Err)
+}
+
+func (e *MathError) Unwrap() error {
+	return e.Err
+}
+
+// DiagramError is returned when a diagram renderer fails on a fenced code
+// block; the block is printed as code instead.
+type DiagramError struct {
+	Info string
+	Err  error
+}
+
+func (e *DiagramError) Error() string {
+	return fmt.Sprintf("%v diagram: %v", e.Info, e.Err)
+}
+
+func (e *DiagramError) Unwrap() error {
+	return e.Err
+}
+
+// CodeBlockError is returned when a fenced code block handler cannot render
+// a block, e.g. malformed csv; the block is printed as code instead.
+type CodeBlockError struct {
+	Info string
+	Err  error
+}
+
+func (e *CodeBlockError) Error() string {
+	return fmt.Sprintf("%v code block: %v", e.Info, e.Err)
+}
+
+func (e *CodeBlockError) Unwrap() error {
+	return e.Err
+}
+
+// FrontMatterError is returned when the YAML or TOML front matter of a
+// document cannot be parsed; the document is then rendered as is.
+type FrontMatterError struct {
+	Err error
+}
+
+func (e *FrontMatterError) Error() string {
+	return fmt.Sprintf("front matter: %v", e.Err)
+}
+
+func (e *FrontMatterError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedNodeError is returned when the renderer encounters an AST node it cannot render.
+type UnsupportedNodeError struct {
+	Node ast.Node
+}
+
+func (e *UnsupportedNodeError) Error() string {
+	return fmt.Sprintf("unsupported node type: %T", e.Node)
+}
+
+// fail records err. In strict mode the first error aborts rendering and is
+// returned by Process; otherwise it is kept as a warning and rendering continues.
+func (r *PdfRenderer) fail(err error) {
+	r.tracer("Error", err.Error())
+	if r.StrictErrors {
+		if r.err == nil {
+			r.err = err
+		}
+		return
+	}
+	r.warnings = append(r.warnings, err)
+}
+
+// Err returns the error which aborted rendering, if any
+func (r *PdfRenderer) Err() error {
+	return r.err
+}
+
+// Warnings returns the errors encountered (and skipped) while rendering in lenient mode
+func (r *PdfRenderer) Warnings() []error {
+	return r.warnings
+}
@@ -0,0 +1,543 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// graphShape is the outline drawn around a flowchart node
+type graphShape int
+
+const (
+	shapeRect graphShape = iota
+	shapeRound
+	shapeStadium
+	shapeCircle
+	shapeEllipse
+	shapeDiamond
+	shapeHexagon
+	shapeText // label only
+)
+
+type graphNode struct {
+	id    string
+	label []string
+	shape graphShape
+	dummy bool // a bend point of an edge spanning several ranks
+	rank  int
+	order float64
+	// center and size
+	x, y, w, h float64
+}
+
+type graphEdge struct {
+	from, to int
+	label    string
+	dashed   bool
+	thick    bool
+	arrow    bool // arrow head at to
+	back     bool // part of a cycle; laid out from to to from
+	path     []int
+}
+
+// graph is a flowchart laid out in ranks, following the layered approach
+// of graphviz's dot: nodes are ranked along the direction of the edges,
+// ordered within ranks to limit crossings and then given coordinates.
+type graph struct {
+	nodes      []*graphNode
+	ids        map[string]int
+	edges      []*graphEdge
+	direction  string // TB, BT, LR or RL
+	shape      graphShape
+	style      Styler
+	background Color
+	width      float64
+	height     float64
+}
+
+func newGraph(r *PdfRenderer, shape graphShape) *graph {
+	return &graph{
+		ids:        map[string]int{},
+		direction:  "TB",
+		shape:      shape,
+		style:      r.Diagram,
+		background: r.BackgroundColor,
+	}
+}
+
+// node returns the node with the given id, adding it if it is new
+func (g *graph) node(id string) *graphNode {
+	if i, ok := g.ids[id]; ok {
+		return g.nodes[i]
+	}
+	n := &graphNode{id: id, label: []string{id}, shape: g.shape}
+	g.ids[id] = len(g.nodes)
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+func (g *graph) addEdge(from, to string) *graphEdge {
+	g.node(from)
+	g.node(to)
+	e := &graphEdge{from: g.ids[from], to: g.ids[to], arrow: true}
+	g.edges = append(g.edges, e)
+	return e
+}
+
+// setLabel splits a node label into lines on <br> and \n
+func (n *graphNode) setLabel(s string) {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", `\n`, "\n").Replace(s)
+	n.label = strings.Split(s, "\n")
+}
+
+func (g *graph) lineHeight() float64 {
+	return 1.2 * g.style.Size
+}
+
+func (g *graph) vertical() bool {
+	return g.direction == "TB" || g.direction == "BT"
+}
+
+// layout ranks, orders and places the nodes, measuring labels with pdf
+func (g *graph) layout(pdf *fpdf.Fpdf) {
+	pdf.SetFont(g.style.Font, "", g.style.Size)
+	size := g.style.Size
+	for _, n := range g.nodes {
+		for _, l := range n.label {
+			n.w = math.Max(n.w, pdf.GetStringWidth(l))
+		}
+		n.w += 2 * size
+		n.h = float64(len(n.label))*g.lineHeight() + size
+		switch n.shape {
+		case shapeCircle:
+			n.w = math.Max(n.w, n.h)
+			n.h = n.w
+		case shapeEllipse:
+			n.w *= 1.25
+			n.h *= 1.3
+		case shapeDiamond:
+			n.w *= 1.6
+			n.h *= 1.8
+		case shapeHexagon:
+			n.w += n.h / 2
+		}
+	}
+	g.rank()
+	layers := g.addDummies()
+	g.order(layers)
+	g.place(layers, pdf)
+}
+
+// rank assigns each node the length of the longest path leading to it,
+// after reversing the edges which close cycles
+func (g *graph) rank() {
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make([]int, len(g.nodes))
+	out := make([][]*graphEdge, len(g.nodes))
+	for _, e := range g.edges {
+		out[e.from] = append(out[e.from], e)
+	}
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = onStack
+		for _, e := range out[i] {
+			switch state[e.to] {
+			case unvisited:
+				visit(e.to)
+			case onStack:
+				e.back = true
+			}
+		}
+		state[i] = done
+	}
+	for i := range g.nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	// longest path, in topological order
+	indegree := make([]int, len(g.nodes))
+	succ := make([][]int, len(g.nodes))
+	for _, e := range g.edges {
+		from, to := e.ends()
+		if from == to {
+			continue
+		}
+		succ[from] = append(succ[from], to)
+		indegree[to]++
+	}
+	var queue []int
+	for i := range g.nodes {
+		if indegree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range succ[i] {
+			g.nodes[j].rank = max(g.nodes[j].rank, g.nodes[i].rank+1)
+			if indegree[j]--; indegree[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+}
+
+// ends returns the ends of the edge in layout direction
+func (e *graphEdge) ends() (from, to int) {
+	if e.back {
+		return e.to, e.from
+	}
+	return e.from, e.to
+}
+
+// addDummies breaks edges spanning several ranks with a dummy node on each
+// rank crossed, and returns the nodes of each rank
+func (g *graph) addDummies() [][]int {
+	for _, e := range g.edges {
+		from, to := e.ends()
+		e.path = []int{from}
+		for rank := g.nodes[from].rank + 1; rank < g.nodes[to].rank; rank++ {
+			g.nodes = append(g.nodes, &graphNode{dummy: true, rank: rank, w: g.style.Size, h: g.style.Size})
+			e.path = append(e.path, len(g.nodes)-1)
+		}
+		e.path = append(e.path, to)
+	}
+	var layers [][]int
+	for i, n := range g.nodes {
+		for len(layers) <= n.rank {
+			layers = append(layers, nil)
+		}
+		n.order = float64(len(layers[n.rank]))
+		layers[n.rank] = append(layers[n.rank], i)
+	}
+	return layers
+}
+
+// neighbours returns the nodes linked to each node in the rank above and
+// in the rank below
+func (g *graph) neighbours() (up, down [][]int) {
+	up = make([][]int, len(g.nodes))
+	down = make([][]int, len(g.nodes))
+	for _, e := range g.edges {
+		for k := 1; k < len(e.path); k++ {
+			a, b := e.path[k-1], e.path[k]
+			if a == b {
+				continue
+			}
+			down[a] = append(down[a], b)
+			up[b] = append(up[b], a)
+		}
+	}
+	return up, down
+}
+
+// order reduces edge crossings by sorting each rank by the mean position
+// of the neighbours in the rank above, then in the rank below
+func (g *graph) order(layers [][]int) {
+	up, down := g.neighbours()
+	sortLayer := func(layer []int, neighbours [][]int) {
+		for _, i := range layer {
+			if len(neighbours[i]) == 0 {
+				continue
+			}
+			sum := 0.0
+			for _, j := range neighbours[i] {
+				sum += g.nodes[j].order
+			}
+			g.nodes[i].order = sum / float64(len(neighbours[i]))
+		}
+		sort.SliceStable(layer, func(a, b int) bool {
+			return g.nodes[layer[a]].order < g.nodes[layer[b]].order
+		})
+		for k, i := range layer {
+			g.nodes[i].order = float64(k)
+		}
+	}
+	for iter := 0; iter < 4; iter++ {
+		for k := 1; k < len(layers); k++ {
+			sortLayer(layers[k], up)
+		}
+		for k := len(layers) - 2; k >= 0; k-- {
+			sortLayer(layers[k], down)
+		}
+	}
+}
+
+// place sets the node coordinates: ranks are stacked along the direction
+// of the graph, and nodes centered on their neighbours in the ranks above
+// and below
+func (g *graph) place(layers [][]int, pdf *fpdf.Fpdf) {
+	// extent of a node along and across the ranks
+	along := func(n *graphNode) float64 {
+		if g.vertical() {
+			return n.h
+		}
+		return n.w
+	}
+	across := func(n *graphNode) float64 {
+		if g.vertical() {
+			return n.w
+		}
+		return n.h
+	}
+
+	// leave room for the edge labels between ranks
+	gap := 2 * g.lineHeight()
+	for _, e := range g.edges {
+		if e.label == "" {
+			continue
+		}
+		if g.vertical() {
+			gap = math.Max(gap, 2*g.lineHeight()+g.style.Size)
+		} else {
+			gap = math.Max(gap, pdf.GetStringWidth(e.label)+2*g.style.Size)
+		}
+	}
+	sep := 1.5 * g.style.Size
+
+	main := make([]float64, len(g.nodes))
+	cross := make([]float64, len(g.nodes))
+	pos := 0.0
+	for _, layer := range layers {
+		thick := 0.0
+		for _, i := range layer {
+			thick = math.Max(thick, along(g.nodes[i]))
+		}
+		for _, i := range layer {
+			main[i] = pos + thick/2
+		}
+		pos += thick + gap
+	}
+
+	// center the nodes on their neighbours, keeping them apart
+	sweep := func(layer []int, neighbours [][]int) {
+		right := math.Inf(-1)
+		for _, i := range layer {
+			// the bends of long edges only count for nodes with no other neighbours
+			want, count := cross[i], 0
+			for _, dummies := range []bool{false, true} {
+				sum := 0.0
+				for _, j := range neighbours[i] {
+					if g.nodes[j].dummy == dummies {
+						sum += cross[j]
+						count++
+					}
+				}
+				if count > 0 {
+					want = sum / float64(count)
+					break
+				}
+			}
+			half := across(g.nodes[i]) / 2
+			cross[i] = math.Max(want, right+sep+half)
+			right = cross[i] + half
+		}
+	}
+	up, down := g.neighbours()
+	none := make([][]int, len(g.nodes))
+	for _, layer := range layers {
+		sweep(layer, none)
+	}
+	for k := 1; k < len(layers); k++ {
+		sweep(layers[k], up)
+	}
+	for k := len(layers) - 2; k >= 0; k-- {
+		sweep(layers[k], down)
+	}
+
+	// move everything to the origin, leaving room for the line widths
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, n := range g.nodes {
+		n.x, n.y = cross[i], main[i]
+		if !g.vertical() {
+			n.x, n.y = main[i], cross[i]
+		}
+		minX, maxX = math.Min(minX, n.x-n.w/2), math.Max(maxX, n.x+n.w/2)
+		minY, maxY = math.Min(minY, n.y-n.h/2), math.Max(maxY, n.y+n.h/2)
+	}
+	// self loops stick out on the right
+	for _, e := range g.edges {
+		if e.from == e.to {
+			n := g.nodes[e.from]
+			maxX = math.Max(maxX, n.x+n.w/2+2*g.style.Size+pdf.GetStringWidth(e.label))
+		}
+	}
+	const border = 2
+	g.width = maxX - minX + 2*border
+	g.height = maxY - minY + 2*border
+	for _, n := range g.nodes {
+		n.x += border - minX
+		n.y += border - minY
+		if g.direction == "BT" {
+			n.y = g.height - n.y
+		}
+		if g.direction == "RL" {
+			n.x = g.width - n.x
+		}
+	}
+}
+
+func (g *graph) Size() (w, h float64) {
+	return g.width, g.height
+}
+
+func (g *graph) Draw(pdf *fpdf.Fpdf, x, y float64) {
+	tc, fc := g.style.TextColor, g.style.FillColor
+	pdf.SetDrawColor(tc.Red, tc.Green, tc.Blue)
+	pdf.SetTextColor(tc.Red, tc.Green, tc.Blue)
+	pdf.SetFont(g.style.Font, "", g.style.Size)
+	pdf.SetLineJoinStyle("round")
+	for _, e := range g.edges {
+		g.drawEdge(pdf, e, x, y)
+	}
+	for _, n := range g.nodes {
+		if n.dummy {
+			continue
+		}
+		pdf.SetFillColor(fc.Red, fc.Green, fc.Blue)
+		pdf.SetLineWidth(0.75)
+		drawShape(pdf, n.shape, x+n.x, y+n.y, n.w, n.h)
+		lh := g.lineHeight()
+		top := y + n.y - float64(len(n.label))*lh/2
+		for k, l := range n.label {
+			pdf.Text(x+n.x-pdf.GetStringWidth(l)/2, top+(float64(k)+0.5)*lh+0.3*g.style.Size, l)
+		}
+	}
+	pdf.SetLineJoinStyle("miter")
+}
+
+// drawShape draws a node outline centered on cx, cy
+func drawShape(pdf *fpdf.Fpdf, shape graphShape, cx, cy, w, h float64) {
+	x, y := cx-w/2, cy-h/2
+	switch shape {
+	case shapeRect:
+		pdf.Rect(x, y, w, h, "FD")
+	case shapeRound:
+		pdf.RoundedRect(x, y, w, h, math.Min(w, h)/5, "1234", "FD")
+	case shapeStadium:
+		pdf.RoundedRect(x, y, w, h, h/2, "1234", "FD")
+	case shapeCircle, shapeEllipse:
+		pdf.Ellipse(cx, cy, w/2, h/2, 0, "FD")
+	case shapeDiamond:
+		pdf.Polygon([]fpdf.PointType{{X: cx, Y: y}, {X: x + w, Y: cy}, {X: cx, Y: y + h}, {X: x, Y: cy}}, "FD")
+	case shapeHexagon:
+		d := h / 4
+		pdf.Polygon([]fpdf.PointType{{X: x + d, Y: y}, {X: x + w - d, Y: y}, {X: x + w, Y: cy},
+			{X: x + w - d, Y: y + h}, {X: x + d, Y: y + h}, {X: x, Y: cy}}, "FD")
+	}
+}
+
+// clip returns the point where the segment from the center of n towards
+// px, py leaves the outline of n
+func (n *graphNode) clip(px, py float64) (float64, float64) {
+	dx, dy := px-n.x, py-n.y
+	if n.dummy || (dx == 0 && dy == 0) {
+		return n.x, n.y
+	}
+	var t float64
+	switch n.shape {
+	case shapeCircle, shapeEllipse:
+		t = 1 / math.Hypot(dx/(n.w/2), dy/(n.h/2))
+	case shapeDiamond:
+		t = 1 / (math.Abs(dx)/(n.w/2) + math.Abs(dy)/(n.h/2))
+	default:
+		t = 1 / math.Max(math.Abs(dx)/(n.w/2), math.Abs(dy)/(n.h/2))
+	}
+	return n.x + t*dx, n.y + t*dy
+}
+
+func (g *graph) drawEdge(pdf *fpdf.Fpdf, e *graphEdge, x, y float64) {
+	size := g.style.Size
+	pdf.SetLineWidth(0.75)
+	if e.thick {
+		pdf.SetLineWidth(1.5)
+	}
+	if e.dashed {
+		pdf.SetDashPattern([]float64{3, 2}, 0)
+	}
+	var pts []fpdf.PointType
+	if e.from == e.to {
+		// a loop on the right side of the node
+		n := g.nodes[e.from]
+		r := n.x + n.w/2
+		pdf.MoveTo(x+r, y+n.y-n.h/4)
+		pdf.CurveBezierCubicTo(x+r+2*size, y+n.y-n.h/2, x+r+2*size, y+n.y+n.h/2, x+r, y+n.y+n.h/4)
+		pdf.DrawPath("D")
+		pts = []fpdf.PointType{{X: r + size, Y: n.y + n.h/4 + size/4}, {X: r, Y: n.y + n.h/4}}
+	} else {
+		for _, i := range e.path {
+			pts = append(pts, fpdf.PointType{X: g.nodes[i].x, Y: g.nodes[i].y})
+		}
+		if e.back {
+			for a, b := 0, len(pts)-1; a < b; a, b = a+1, b-1 {
+				pts[a], pts[b] = pts[b], pts[a]
+			}
+		}
+		last := len(pts) - 1
+		pts[0].X, pts[0].Y = g.nodes[e.from].clip(pts[1].X, pts[1].Y)
+		pts[last].X, pts[last].Y = g.nodes[e.to].clip(pts[last-1].X, pts[last-1].Y)
+		for k := 1; k < len(pts); k++ {
+			pdf.Line(x+pts[k-1].X, y+pts[k-1].Y, x+pts[k].X, y+pts[k].Y)
+		}
+	}
+	pdf.SetDashPattern([]float64{}, 0)
+	if e.arrow {
+		tc := g.style.TextColor
+		pdf.SetFillColor(tc.Red, tc.Green, tc.Blue)
+		drawArrowHead(pdf, x+pts[len(pts)-2].X, y+pts[len(pts)-2].Y, x+pts[len(pts)-1].X, y+pts[len(pts)-1].Y, 0.6*size)
+	}
+	if e.label == "" {
+		return
+	}
+	// on the middle segment, or on the right of a loop
+	var lx, ly float64
+	if e.from == e.to {
+		n := g.nodes[e.from]
+		lx, ly = n.x+n.w/2+2*size+pdf.GetStringWidth(e.label)/2, n.y
+	} else {
+		k := len(pts) / 2
+		lx, ly = (pts[k-1].X+pts[k].X)/2, (pts[k-1].Y+pts[k].Y)/2
+	}
+	w := pdf.GetStringWidth(e.label)
+	bg := g.background
+	pdf.SetFillColor(bg.Red, bg.Green, bg.Blue)
+	pdf.Rect(x+lx-w/2-1, y+ly-g.lineHeight()/2, w+2, g.lineHeight(), "F")
+	pdf.Text(x+lx-w/2, y+ly+0.3*size, e.label)
+}
+
+// drawArrowHead draws a filled arrow head pointing at x2, y2 along the
+// line coming from x1, y1
+func drawArrowHead(pdf *fpdf.Fpdf, x1, y1, x2, y2, length float64) {
+	d := math.Hypot(x2-x1, y2-y1)
+	if d == 0 {
+		return
+	}
+	ux, uy := (x2-x1)/d, (y2-y1)/d
+	bx, by := x2-length*ux, y2-length*uy
+	hw := length / 2.5
+	pdf.Polygon([]fpdf.PointType{
+		{X: x2, Y: y2},
+		{X: bx - hw*uy, Y: by + hw*ux},
+		{X: bx + hw*uy, Y: by - hw*ux},
+	}, "F")
+}
@@ -0,0 +1,274 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// FootnoteMode defines where footnotes (enabled with parser.Footnotes) are rendered
+type FootnoteMode int
+
+const (
+	// FootnotesPageBottom renders each note at the bottom of the page
+	// on which it is referenced, spilling over to the next page if needed
+	FootnotesPageBottom FootnoteMode = iota
+	// FootnotesEndnotes renders all notes as a list at the end of the document
+	FootnotesEndnotes
+)
+
+// footnote is a note waiting to be drawn at the bottom of the page
+type footnote struct {
+	label     string
+	link      int
+	lines     []string
+	continued bool // spilled over from the previous page
+}
+
+type footnoteState struct {
+	// internal link IDs, keyed by the footnote reference
+	links map[string]int
+	// the links of the notes placed or pending, which later references
+	// to the same note only link to
+	queued map[int]bool
+	// notes to be drawn at the bottom of the current page
+	pending []*footnote
+	// height reserved for them above the bottom margin
+	reserved float64
+}
+
+// footnoteLink returns the internal link ID pointing at the footnote text
+func (r *PdfRenderer) footnoteLink(ref string) int {
+	if r.fn.links == nil {
+		r.fn.links = make(map[string]int)
+	}
+	link, ok := r.fn.links[ref]
+	if !ok {
+		link = r.Pdf.AddLink()
+		r.fn.links[ref] = link
+	}
+	return link
+}
+
+// footnoteText flattens the footnote content into plain text,
+// one line per paragraph
+func footnoteText(node ast.Node) string {
+	var text strings.Builder
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.Text:
+			if entering {
+				text.WriteString(strings.ReplaceAll(string(n.Literal), "\n", " "))
+			}
+		case *ast.Code:
+			text.Write(n.Literal)
+		case *ast.Softbreak, *ast.Hardbreak:
+			text.WriteString(" ")
+		case *ast.Paragraph:
+			if !entering && ast.GetNextNode(n) != nil {
+				text.WriteString("\n")
+			}
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(text.String())
+}
+
+func (r *PdfRenderer) footnoteLineHeight() float64 {
+	return r.Footnote.Size + r.Footnote.Spacing
+}
+
+// footnoteSeparator is the space taken by the rule above the notes
+func (r *PdfRenderer) footnoteSeparator() float64 {
+	return r.footnoteLineHeight()
+}
+
+// footnoteScale is the size of the footnote references, relative to the
+// text around them
+const footnoteScale = 0.6
+
+func (r *PdfRenderer) processFootnoteRef(node *ast.Link, entering bool) {
+	if !entering {
+		return
+	}
+	ref := string(node.Destination)
+	label := strconv.Itoa(node.NoteID)
+	r.tracer("Footnote reference", fmt.Sprintf("NoteID[%v] Ref[%v]", node.NoteID, ref))
+	link := r.footnoteLink(ref)
+	if r.tbl.incell {
+		// the note is queued when the row is drawn, on its page
+		fn := &cellFootnote{link: link}
+		if r.FootnoteMode == FootnotesPageBottom && node.Footnote != nil {
+			fn.text = footnoteText(node.Footnote)
+		}
+		r.addCellRun(tableRun{text: label, style: r.cs.peek().textStyle, footnote: fn})
+		return
+	}
+	currentStyle := r.cs.peek().textStyle
+	r.setStyler(currentStyle)
+	r.Pdf.SubWrite(currentStyle.Size+currentStyle.Spacing, label,
+		currentStyle.Size*footnoteScale, currentStyle.Size*(1-footnoteScale), link, "")
+	if r.FootnoteMode == FootnotesPageBottom && node.Footnote != nil {
+		r.addFootnote(label, link, footnoteText(node.Footnote))
+		r.setStyler(currentStyle)
+	}
+}
+
+// footnoteWidth is the width available for the note text
+func (r *PdfRenderer) footnoteWidth() float64 {
+	w, _ := r.Pdf.GetPageSize()
+	return w - r.mleft - r.mright - r.footnoteIndent()
+}
+
+func (r *PdfRenderer) footnoteIndent() float64 {
+	return 2 * r.em
+}
+
+// addFootnote queues a note for the bottom of the current page and
+// moves the page break trigger up to make room for it, unless the note
+// was queued already, e.g. for an earlier reference or header row
+func (r *PdfRenderer) addFootnote(label string, link int, text string) {
+	if r.fn.queued == nil {
+		r.fn.queued = make(map[int]bool)
+	}
+	if r.fn.queued[link] {
+		return
+	}
+	r.fn.queued[link] = true
+	r.setStyler(r.Footnote)
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		lines = append(lines, r.Pdf.SplitText(para, r.footnoteWidth())...)
+	}
+	r.fn.pending = append(r.fn.pending, &footnote{label: label, link: link, lines: lines})
+
+	// the notes must stay below the line holding the reference
+	_, h := r.Pdf.GetPageSize()
+	lh := r.cs.peek().textStyle.Size + r.cs.peek().textStyle.Spacing
+	available := h - r.mbottom - (r.Pdf.GetY() + lh)
+	r.reserveFootnotes(available)
+}
+
+// reserveFootnotes reserves room for the pending notes, at most available;
+// notes which don't fit spill over to the next page
+func (r *PdfRenderer) reserveFootnotes(available float64) {
+	needed := r.footnoteSeparator()
+	for _, fn := range r.fn.pending {
+		needed += float64(len(fn.lines)) * r.footnoteLineHeight()
+	}
+	r.fn.reserved = min(needed, max(available, 0))
+	r.tracer("Footnotes", fmt.Sprintf("reserved %v of %v", r.fn.reserved, needed))
+	r.Pdf.SetAutoPageBreak(true, r.pageBreakMargin())
+}
+
+// startFootnotePage is called for every new page and reserves room
+// for the notes which spilled over from the previous page
+func (r *PdfRenderer) startFootnotePage() {
+	if len(r.fn.pending) == 0 {
+		return
+	}
+	_, h := r.Pdf.GetPageSize()
+	r.reserveFootnotes((h - r.mtop - r.mbottom) / 2)
+}
+
+// flushFootnotes draws the pending notes at the bottom of the current page
+func (r *PdfRenderer) flushFootnotes() {
+	if len(r.fn.pending) == 0 {
+		return
+	}
+	r.tracer("Footnotes", fmt.Sprintf("placing %v notes", len(r.fn.pending)))
+
+	// save the state of the page body
+	x, y := r.Pdf.GetXY()
+	lm, _, _, _ := r.Pdf.GetMargins()
+	family, style := r.Pdf.GetFontFamily(), r.Pdf.GetFontStyle()
+	size, _ := r.Pdf.GetFontSize()
+	tr, tg, tb := r.Pdf.GetTextColor()
+	fr, fg, fb := r.Pdf.GetFillColor()
+	dr, dg, db := r.Pdf.GetDrawColor()
+	lw := r.Pdf.GetLineWidth()
+	r.Pdf.SetAutoPageBreak(false, 0)
+
+	_, h := r.Pdf.GetPageSize()
+	bottom := h - r.mbottom
+	lh := r.footnoteLineHeight()
+	top := bottom - r.fn.reserved
+	c := r.Footnote.TextColor
+	r.Pdf.SetDrawColor(c.Red, c.Green, c.Blue)
+	r.Pdf.SetLineWidth(0.5)
+	r.Pdf.Line(r.mleft, top+r.footnoteSeparator()/2,
+		r.mleft+(r.footnoteWidth()+r.footnoteIndent())/3, top+r.footnoteSeparator()/2)
+
+	r.setStyler(r.Footnote)
+	r.Pdf.SetLeftMargin(r.mleft)
+	cur := top + r.footnoteSeparator()
+	var carry []*footnote
+	for n, fn := range r.fn.pending {
+		for i, line := range fn.lines {
+			if cur+lh > bottom+0.01 {
+				carry = append(carry, &footnote{label: fn.label, lines: fn.lines[i:], continued: true})
+				carry = append(carry, r.fn.pending[n+1:]...)
+				break
+			}
+			if i == 0 && !fn.continued {
+				r.Pdf.SetLink(fn.link, cur, -1)
+				r.Pdf.SetXY(r.mleft, cur)
+				r.Pdf.SubWrite(lh, fn.label, r.Footnote.Size*0.7, r.Footnote.Size*0.3, 0, "")
+			}
+			r.Pdf.SetXY(r.mleft+r.footnoteIndent(), cur)
+			r.Pdf.CellFormat(r.footnoteWidth(), lh, line, "", 0, "L", false, 0, "")
+			cur += lh
+		}
+		if carry != nil {
+			break
+		}
+	}
+	r.fn.pending = carry
+	r.fn.reserved = 0
+
+	// restore the state of the page body
+	r.Pdf.SetAutoPageBreak(true, r.mbottom)
+	r.Pdf.SetLeftMargin(lm)
+	r.Pdf.SetFont(family, style, size)
+	r.Pdf.SetTextColor(tr, tg, tb)
+	r.Pdf.SetFillColor(fr, fg, fb)
+	r.Pdf.SetDrawColor(dr, dg, db)
+	r.Pdf.SetLineWidth(lw)
+	r.Pdf.SetXY(x, y)
+}
+
+// flushAllFootnotes places all remaining notes, adding pages as needed
+func (r *PdfRenderer) flushAllFootnotes() {
+	for len(r.fn.pending) > 0 {
+		r.flushFootnotes()
+		if len(r.fn.pending) > 0 {
+			r.Pdf.AddPage()
+		}
+	}
+}
+
+// processFootnotes handles the block which precedes the list of notes
+func (r *PdfRenderer) processFootnotes(node *ast.Footnotes, entering bool) {
+	if r.FootnoteMode != FootnotesEndnotes || !entering {
+		return
+	}
+	r.tracer("Footnotes (endnotes)", "")
+	r.cr()
+	x, y := r.Pdf.GetXY()
+	w, _ := r.Pdf.GetPageSize()
+	c := r.Footnote.TextColor
+	r.Pdf.SetDrawColor(c.Red, c.Green, c.Blue)
+	r.Pdf.SetLineWidth(0.5)
+	r.Pdf.Line(x, y, x+(w-r.mleft-r.mright)/3, y)
+}
@@ -0,0 +1,309 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"gopkg.in/yaml.v2"
+)
+
+// FrontMatter holds the document options read from a leading YAML block
+// (between --- lines) or TOML block (between +++ lines):
+//
+//	---
+//	title: Release notes
+//	author: Jane Doe
+//	keywords: [release, notes]
+//	theme: dark
+//	papersize: A5
+//	toc: true
+//	---
+//
+// Keys are matched case-insensitively; unknown keys are ignored.
+type FrontMatter struct {
+	Title, Author, Subject string
+	Keywords               []string
+	// Theme is light, dark or the path of a custom theme JSON file,
+	// relative to the directory of the document (see ResolveTheme)
+	Theme       string
+	PaperSize   string
+	Orientation string
+	TOC, Footer bool
+}
+
+// ParseFrontMatter splits the front matter, if any, from the markdown
+// content. Content without front matter, including content whose leading
+// block isn't a YAML or TOML mapping, is returned as is, with a nil
+// FrontMatter; an error is returned for a mapping with invalid values.
+func ParseFrontMatter(content []byte) (*FrontMatter, []byte, error) {
+	s := markdown.NormalizeNewlines(content)
+	open, _, _ := bytes.Cut(s, []byte("\n"))
+	var closing []string
+	switch strings.TrimRight(string(open), " \t") {
+	case "---":
+		closing = []string{"---", "..."}
+	case "+++":
+		closing = []string{"+++"}
+	default:
+		return nil, content, nil
+	}
+
+	// find the closing line; without one, the first line is a rule
+	start := len(open) + 1
+	for i := start; i < len(s); {
+		line, _, _ := bytes.Cut(s[i:], []byte("\n"))
+		next := i + len(line) + 1
+		for _, c := range closing {
+			if strings.TrimRight(string(line), " \t") != c {
+				continue
+			}
+			var values map[string]interface{}
+			var err error
+			if c == "+++" {
+				values, err = parseTOML(string(s[start:i]))
+			} else {
+				err = yaml.Unmarshal(s[start:i], &values)
+			}
+			// a block which isn't a mapping is text between two rules,
+			// e.g. a slide
+			if err != nil || len(values) == 0 {
+				return nil, content, nil
+			}
+			fm := new(FrontMatter)
+			if err := fm.set(values); err != nil {
+				return nil, content, &FrontMatterError{Err: err}
+			}
+			return fm, s[min(next, len(s)):], nil
+		}
+		i = next
+	}
+	return nil, content, nil
+}
+
+// set copies the known keys of values into fm
+func (fm *FrontMatter) set(values map[string]interface{}) error {
+	for key, value := range values {
+		var err error
+		switch strings.ToLower(key) {
+		case "title":
+			fm.Title, err = frontMatterString(key, value)
+		case "author":
+			fm.Author, err = frontMatterString(key, value)
+		case "subject":
+			fm.Subject, err = frontMatterString(key, value)
+		case "theme":
+			fm.Theme, err = frontMatterString(key, value)
+		case "papersize":
+			fm.PaperSize, err = frontMatterString(key, value)
+		case "orientation":
+			fm.Orientation, err = frontMatterString(key, value)
+		case "toc":
+			fm.TOC, err = frontMatterBool(key, value)
+		case "footer":
+			fm.Footer, err = frontMatterBool(key, value)
+		case "keywords":
+			// a list, or a single comma separated string
+			switch v := value.(type) {
+			case []interface{}:
+				for _, k := range v {
+					fm.Keywords = append(fm.Keywords, fmt.Sprint(k))
+				}
+			case string:
+				for _, k := range strings.Split(v, ",") {
+					if k = strings.TrimSpace(k); k != "" {
+						fm.Keywords = append(fm.Keywords, k)
+					}
+				}
+			default:
+				err = fmt.Errorf("%v: want a list, got %v", key, value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func frontMatterString(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("%v: want a string, got %v", key, value)
+}
+
+func frontMatterBool(key string, value interface{}) (bool, error) {
+	if v, ok := value.(bool); ok {
+		return v, nil
+	}
+	return false, fmt.Errorf("%v: want true or false, got %v", key, value)
+}
+
+// parseTOML reads the top level keys of a TOML document: strings, numbers,
+// booleans and single line arrays of them. Keys under [tables] are skipped.
+func parseTOML(doc string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	inTable := false
+	for n, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			inTable = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		v, rest, err := tomlValue(strings.TrimSpace(value))
+		if err == nil && rest != "" && rest[0] != '#' {
+			err = fmt.Errorf("unexpected %q", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if !inTable {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+// tomlValue reads the value at the start of s, returning it with the
+// rest of s
+func tomlValue(s string) (interface{}, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"':
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		return v, strings.TrimSpace(s[end+1:]), err
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+	case '[':
+		var list []interface{}
+		s = strings.TrimSpace(s[1:])
+		for s != "" && s[0] != ']' {
+			v, rest, err := tomlValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, v)
+			s = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+		}
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+		return list, strings.TrimSpace(s[1:]), nil
+	}
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], strings.TrimSpace(s[end:])
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if i, err := strconv.Atoi(word); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", word)
+}
+
+// ResolveTheme returns the theme given by the front matter: LIGHT or DARK,
+// or CUSTOM and the path of the theme file. Only files within dir, the
+// directory of the document, may be named, so that a document can't have
+// any file of the system read; with dir "", no theme file is accepted.
+func (fm FrontMatter) ResolveTheme(dir string) (Theme, string, error) {
+	switch fm.Theme {
+	case "", "light":
+		return LIGHT, "", nil
+	case "dark":
+		return DARK, "", nil
+	}
+	if dir == "" || !filepath.IsLocal(fm.Theme) {
+		return LIGHT, "", &FrontMatterError{Err: fmt.Errorf("theme %q is neither light, dark nor a file within the document directory", fm.Theme)}
+	}
+	return CUSTOM, filepath.Join(dir, fm.Theme), nil
+}
+
+// Apply sets the metadata, theme, paper size and orientation given by
+// the front matter on params, and adds the options for the table of
+// contents and page footer to its Opts; dir is the directory of the document, which
+// a custom theme file must be in (see ResolveTheme). The theme is left
+// as it is if the front matter names another file.
+func (fm FrontMatter) Apply(params *PdfRendererParams, dir string) error {
+	if fm.Title != "" {
+		params.Metadata.Title = fm.Title
+	}
+	if fm.Author != "" {
+		params.Metadata.Author = fm.Author
+	}
+	if fm.Subject != "" {
+		params.Metadata.Subject = fm.Subject
+	}
+	if len(fm.Keywords) > 0 {
+		params.Metadata.Keywords = fm.Keywords
+	}
+	if fm.PaperSize != "" {
+		params.Papersz = fm.PaperSize
+	}
+	if fm.Orientation != "" {
+		params.Orientation = fm.Orientation
+	}
+	if fm.TOC {
+		params.Opts = append(params.Opts, WithTableOfContents(TOCOptions{}))
+	}
+	if fm.Footer {
+		params.Opts = append(params.Opts, WithFooter(PageTemplate{Left: "{author}", Center: "{title}", Right: "Page {page}"}))
+	}
+	if fm.Theme == "" {
+		return nil
+	}
+	theme, file, err := fm.ResolveTheme(dir)
+	if err != nil {
+		return err
+	}
+	params.Theme, params.CustomThemeFile = theme, file
+	return nil
+}
@@ -10,6 +10,7 @@ require (
 	github.com/jessp01/gohighlight v0.21.2
 	github.com/mitchellh/go-wordwrap v1.0.1
 	golang.org/x/exp v0.0.0-20240707233637-46b078467d37
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -18,5 +19,4 @@ require (
 	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
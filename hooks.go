@@ -0,0 +1,86 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// NodeRenderer renders a node of the syntax tree in place of the built-in
+// renderer. Like RenderNode, it is called when entering a node and, for
+// container nodes, when leaving it; a renderer handling a container should
+// handle both. It returns false to leave the node to the renderers
+// registered before it, and finally to the built-in one.
+type NodeRenderer func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// renderHooked gives the node to the registered node renderers, the
+// last registered first
+func (r *PdfRenderer) renderHooked(node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	for i := len(r.nodeRenderers) - 1; i >= 0; i-- {
+		if status, ok := r.nodeRenderers[i](r, node, entering); ok {
+			return status, true
+		}
+	}
+	return ast.GoToNext, false
+}
+
+// The methods below let node renderers share the state of the built-in ones.
+
+// CurrentStyle returns the style of the text being written
+func (r *PdfRenderer) CurrentStyle() Styler {
+	return r.cs.peek().textStyle
+}
+
+// PushStyle makes s the style of the text written until the matching
+// PopStyle; the rest of the container state (margins, lists, links) is
+// kept. Push on entering a node and pop on leaving it.
+func (r *PdfRenderer) PushStyle(s Styler) {
+	x := *r.cs.peek()
+	x.textStyle = s
+	r.cs.push(&x)
+}
+
+// PopStyle restores the style in use before the last PushStyle
+func (r *PdfRenderer) PopStyle() {
+	r.cs.pop()
+}
+
+// SetStyler sets the font and the colours of s on the PDF document
+func (r *PdfRenderer) SetStyler(s Styler) {
+	r.setStyler(s)
+}
+
+// WriteText writes text in the current style, flowing it like the text
+// of a paragraph (or into the table cell being rendered)
+func (r *PdfRenderer) WriteText(text string) {
+	s := r.CurrentStyle()
+	if r.tbl.incell {
+		r.addCellRun(tableRun{text: text, style: s, destination: r.cs.peek().destination})
+		return
+	}
+	r.setStyler(s)
+	r.write(s, text)
+}
+
+// NewLine moves to the start of the next line
+func (r *PdfRenderer) NewLine() {
+	r.cr()
+}
+
+// Trace writes a line to the trace file, if any
+func (r *PdfRenderer) Trace(source, msg string) {
+	r.tracer(source, msg)
+}
+
+// Fail reports a rendering error: in strict mode it aborts rendering,
+// otherwise it is added to Warnings
+func (r *PdfRenderer) Fail(err error) {
+	r.fail(err)
+}
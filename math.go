@@ -0,0 +1,985 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+// A small TeX-like typesetter for the math nodes produced by the
+// parser.MathJax extension. Expressions are parsed and laid out in one
+// pass into boxes of glyphs and paths, which are then drawn with the fpdf
+// primitives. Dimensions are in points, with y pointing up from the
+// baseline of the box.
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type mathClass int
+
+// atom classes, as used by TeX to space atoms
+const (
+	mathOrd mathClass = iota
+	mathOp
+	mathBin
+	mathRel
+	mathOpen
+	mathClose
+	mathPunct
+	mathInner
+	mathSkip // explicit spaces; they don't affect the spacing of their neighbours
+)
+
+// mathStyle is the style an expression is laid out in
+type mathStyle struct {
+	base    float64 // size of the surrounding text
+	display bool
+	script  int // 0 for text, 1 for scripts, 2 for scripts of scripts
+}
+
+func (st mathStyle) size() float64 {
+	return st.base * [3]float64{1, 0.7, 0.5}[st.script]
+}
+
+// sub is the style of the scripts of an atom
+func (st mathStyle) sub() mathStyle {
+	st.display = false
+	st.script = min(st.script+1, 2)
+	return st
+}
+
+// frac is the style of the numerator and denominator of a fraction
+func (st mathStyle) frac() mathStyle {
+	if st.display {
+		st.display = false
+		return st
+	}
+	return st.sub()
+}
+
+// axis is the height of the math axis, on which fraction bars, operators
+// and delimiters are centered
+func (st mathStyle) axis() float64 {
+	return 0.25 * st.size()
+}
+
+func (st mathStyle) rule() float64 {
+	return max(0.04*st.size(), 0.4)
+}
+
+type mathGlyph struct {
+	x, y  float64 // origin of the baseline
+	text  string
+	font  string
+	style string
+	size  float64
+}
+
+type mathPathKind int
+
+const (
+	mathLine  mathPathKind = iota // a polyline
+	mathCurve                     // a cubic Bézier curve
+	mathDot                       // a filled circle; width is the radius
+)
+
+type mathPath struct {
+	kind   mathPathKind
+	points []float64 // x, y pairs
+	width  float64
+}
+
+// mathBox is a laid out expression
+type mathBox struct {
+	width, height, depth float64
+	class                mathClass
+	limits               bool // takes its limits above and below
+	glyphs               []mathGlyph
+	paths                []mathPath
+}
+
+// add copies the content of c into b, with its origin at (dx, dy);
+// the dimensions of b are left to the caller
+func (b *mathBox) add(c *mathBox, dx, dy float64) {
+	for _, g := range c.glyphs {
+		g.x += dx
+		g.y += dy
+		b.glyphs = append(b.glyphs, g)
+	}
+	for _, p := range c.paths {
+		points := make([]float64, len(p.points))
+		for i, v := range p.points {
+			if i%2 == 0 {
+				points[i] = v + dx
+			} else {
+				points[i] = v + dy
+			}
+		}
+		p.points = points
+		b.paths = append(b.paths, p)
+	}
+}
+
+func (b *mathBox) path(kind mathPathKind, width float64, points ...float64) {
+	b.paths = append(b.paths, mathPath{kind: kind, points: points, width: width})
+}
+
+// mathParser reads LaTeX source and lays it out as it goes
+type mathParser struct {
+	r   *PdfRenderer
+	src string
+	pos int
+	err error
+
+	// font style of letters; "I" unless changed by \mathrm and friends
+	letters string
+	// equation label set with \tag, and whether numbering is turned off
+	tag   string
+	notag bool
+}
+
+func (p *mathParser) failf(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+// token returns the next token and its length: a command with its
+// backslash, or a single character
+func (p *mathParser) token() (string, int) {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\n\r", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return "", 0
+	}
+	s := p.src[p.pos:]
+	if s[0] == '\\' && len(s) > 1 {
+		n := 1
+		for n < len(s) && isASCIILetter(s[n]) {
+			n++
+		}
+		if n == 1 {
+			_, size := utf8.DecodeRuneInString(s[1:])
+			n += size
+		}
+		return s[:n], n
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size], size
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *mathParser) peek() string {
+	tok, _ := p.token()
+	return tok
+}
+
+func (p *mathParser) next() string {
+	tok, n := p.token()
+	p.pos += n
+	return tok
+}
+
+func (p *mathParser) expect(tok string) {
+	if got := p.next(); got != tok {
+		p.failf("expected %q, got %q", tok, got)
+	}
+}
+
+// rawArg reads a braced argument as plain text
+func (p *mathParser) rawArg() string {
+	if p.peek() != "{" {
+		return p.next()
+	}
+	p.next()
+	start, depth := p.pos, 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start : p.pos-1]
+			}
+		}
+	}
+	p.failf("missing }")
+	return p.src[start:]
+}
+
+// ends reports whether tok closes the list being read
+func ends(tok string) bool {
+	switch tok {
+	case "", "}", "&", `\\`, `\right`, `\end`:
+		return true
+	}
+	return false
+}
+
+// parse lays out a whole expression
+func (p *mathParser) parse(st mathStyle) *mathBox {
+	p.letters = "I"
+	box := p.list(st)
+	switch p.peek() {
+	case "&", `\\`:
+		// several lines without an environment
+		p.pos = 0
+		name := "gathered"
+		if strings.Contains(p.src, "&") {
+			name = "aligned"
+		}
+		box = p.rows(st, name, "")
+	}
+	for p.err == nil && p.peek() != "" {
+		p.failf("unexpected %q", p.next())
+	}
+	return box
+}
+
+// list lays out atoms up to the end of the enclosing group
+func (p *mathParser) list(st mathStyle) *mathBox {
+	var atoms []*mathBox
+	for p.err == nil && !ends(p.peek()) {
+		if atom := p.atom(st); atom != nil {
+			atoms = append(atoms, atom)
+		}
+	}
+	return p.hlist(atoms, st)
+}
+
+// hlist sets atoms side by side, spacing them according to their classes
+func (p *mathParser) hlist(atoms []*mathBox, st mathStyle) *mathBox {
+	box := &mathBox{class: mathOrd}
+	prev := mathSkip
+	for i, atom := range atoms {
+		class := atom.class
+		if class == mathSkip {
+			box.add(atom, box.width, 0)
+			box.width += atom.width
+			continue
+		}
+		// a binary operator with no left operand is an ordinary atom, e.g. -1
+		if class == mathBin {
+			switch prev {
+			case mathSkip, mathOp, mathBin, mathRel, mathOpen, mathPunct:
+				class = mathOrd
+			}
+			if i == len(atoms)-1 {
+				class = mathOrd
+			}
+		}
+		if prev == mathBin && (class == mathRel || class == mathClose || class == mathPunct) {
+			prev = mathOrd
+		}
+		if prev != mathSkip {
+			space := mathSpacing[prev][class]
+			if space < 0 && st.script > 0 {
+				space = 0
+			}
+			box.width += [4]float64{0, 3, 4, 5}[max(space, -space)] / 18 * st.size()
+		}
+		box.add(atom, box.width, 0)
+		box.width += atom.width
+		box.height = max(box.height, atom.height)
+		box.depth = max(box.depth, atom.depth)
+		prev = class
+	}
+	if len(atoms) == 1 {
+		box.class = atoms[0].class
+		box.limits = atoms[0].limits
+	}
+	return box
+}
+
+// atom lays out a nucleus and its scripts
+func (p *mathParser) atom(st mathStyle) *mathBox {
+	base := p.nucleus(st)
+	if base == nil {
+		return nil
+	}
+	var sub, sup *mathBox
+	for p.err == nil {
+		switch p.peek() {
+		case "^":
+			p.next()
+			sup = p.arg(st.sub())
+		case "_":
+			p.next()
+			sub = p.arg(st.sub())
+		case "'":
+			p.next()
+			prime := p.glyph("\xa2", mathSymbolFont, "", st.sub().size(), mathOrd)
+			if sup == nil {
+				sup = prime
+			} else {
+				sup = p.hlist([]*mathBox{sup, prime}, st.sub())
+			}
+		default:
+			if sub == nil && sup == nil {
+				return base
+			}
+			return p.scripts(base, sub, sup, st)
+		}
+	}
+	return base
+}
+
+// arg lays out a braced group or a single nucleus
+func (p *mathParser) arg(st mathStyle) *mathBox {
+	if p.peek() == "{" {
+		p.next()
+		box := p.list(st)
+		p.expect("}")
+		box.class = mathOrd
+		return box
+	}
+	if ends(p.peek()) {
+		p.failf("missing argument")
+		return &mathBox{}
+	}
+	box := p.nucleus(st)
+	if box == nil {
+		return &mathBox{}
+	}
+	return box
+}
+
+// glyph is a box holding a single piece of text
+func (p *mathParser) glyph(text, font, style string, size float64, class mathClass) *mathBox {
+	p.r.Pdf.SetFont(font, style, size)
+	height, depth := glyphExtent(text, font == mathSymbolFont)
+	return &mathBox{
+		width:  p.r.Pdf.GetStringWidth(text),
+		height: height * size, depth: depth * size,
+		class:  class,
+		glyphs: []mathGlyph{{text: text, font: font, style: style, size: size}},
+	}
+}
+
+// glyphExtent estimates the height and depth of text, in ems; the core
+// fonts carry no vertical metrics per glyph
+func glyphExtent(text string, symbol bool) (height, depth float64) {
+	ascenders, descenders := "bdfhklt", "gjpqy(),;[]{}|/"
+	if symbol {
+		ascenders, descenders = "bdqlxzfyJ", "bgzhmxrfcyj"
+	}
+	height = 0.46
+	for _, c := range text {
+		switch {
+		case strings.ContainsRune(".,:;-+=<>~", c):
+		case unicode.IsUpper(c) || unicode.IsDigit(c) || strings.ContainsRune(ascenders, c),
+			c > 0x7f && symbol, !unicode.IsLetter(c):
+			height = max(height, 0.7)
+		}
+		if strings.ContainsRune(descenders, c) {
+			depth = 0.2
+		}
+	}
+	return height, depth
+}
+
+func (p *mathParser) mathFont() string {
+	return p.r.Math.Font
+}
+
+// nucleus lays out a single atom without its scripts
+func (p *mathParser) nucleus(st mathStyle) *mathBox {
+	tok := p.next()
+	size := st.size()
+	switch {
+	case tok == "{":
+		box := p.list(st)
+		p.expect("}")
+		box.class = mathOrd
+		return box
+	case strings.HasPrefix(tok, `\`):
+		return p.command(tok[1:], st)
+	case len(tok) == 1 && isASCIILetter(tok[0]):
+		return p.glyph(tok, p.mathFont(), p.letters, size, mathOrd)
+	}
+	switch tok {
+	case "+", "-", "*":
+		return p.glyph(tok, mathSymbolFont, "", size, mathBin)
+	case "=", "<", ">":
+		return p.glyph(tok, mathSymbolFont, "", size, mathRel)
+	case ":":
+		return p.glyph(tok, p.mathFont(), "", size, mathRel)
+	case ",", ";":
+		return p.glyph(tok, p.mathFont(), "", size, mathPunct)
+	case "(", "[":
+		return p.glyph(tok, p.mathFont(), "", size, mathOpen)
+	case ")", "]", "!", "?":
+		return p.glyph(tok, p.mathFont(), "", size, mathClose)
+	case "'":
+		return p.glyph("\xa2", mathSymbolFont, "", size, mathOrd)
+	case "~":
+		return &mathBox{width: size / 3, class: mathSkip}
+	}
+	return p.glyph(tok, p.mathFont(), strings.ReplaceAll(p.letters, "I", ""), size, mathOrd)
+}
+
+// command lays out a control sequence
+func (p *mathParser) command(name string, st mathStyle) *mathBox {
+	size := st.size()
+	if sym, ok := mathSymbols[name]; ok {
+		box := p.glyph(sym.code, mathSymbolFont, "", size, sym.class)
+		if name == "cdots" {
+			// the dots of \cdot sit on the axis
+			box.height, box.depth = st.axis()+0.1*size, 0
+		}
+		return box
+	}
+	if op, ok := mathLargeOps[name]; ok {
+		return p.largeOp(name, op.code, op.bottom/1000, op.top/1000, op.limits, op.integral, st)
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		box := p.glyph(name, p.mathFont(), "", size, mathOp)
+		box.limits = limits && st.display
+		return box
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		fst := st.frac()
+		switch name {
+		case "dfrac":
+			fst = mathStyle{base: st.base, display: false, script: 0}
+		case "tfrac":
+			fst = mathStyle{base: st.base, display: true}.frac().sub()
+		}
+		num := p.arg(fst)
+		den := p.arg(fst)
+		if name == "binom" {
+			box := p.fraction(num, den, st, false)
+			return p.fence("(", ")", box, st)
+		}
+		return p.fraction(num, den, st, true)
+	case "sqrt":
+		var index *mathBox
+		if p.peek() == "[" {
+			p.next()
+			ist := st.sub().sub()
+			var atoms []*mathBox
+			for p.err == nil && p.peek() != "]" && p.peek() != "" {
+				if atom := p.atom(ist); atom != nil {
+					atoms = append(atoms, atom)
+				}
+			}
+			p.expect("]")
+			index = p.hlist(atoms, ist)
+		}
+		return p.sqrt(p.arg(st), index, st)
+	case "left":
+		left := p.delimiterName()
+		body := p.list(st)
+		if p.next() != `\right` {
+			p.failf(`missing \right`)
+		}
+		right := p.delimiterName()
+		return p.fence(left, right, body, st)
+	case "begin":
+		env := p.rawArg()
+		return p.environment(env, st)
+	case "text", "textrm", "mbox", "textit", "textbf":
+		style := map[string]string{"textit": "I", "textbf": "B"}[name]
+		return p.glyph(p.rawArg(), p.mathFont(), style, size, mathOrd)
+	case "operatorname":
+		return p.glyph(p.rawArg(), p.mathFont(), "", size, mathOp)
+	case "mathrm", "mathbf", "mathit", "mathsf", "mathbb", "mathcal", "boldsymbol":
+		letters := p.letters
+		p.letters = map[string]string{"mathbf": "B", "mathit": "I", "mathbb": "B", "mathcal": "I", "boldsymbol": "BI"}[name]
+		box := p.arg(st)
+		p.letters = letters
+		return box
+	case "hat", "widehat", "bar", "overline", "underline", "vec", "dot", "ddot", "tilde", "widetilde":
+		return p.accent(name, p.arg(st), st)
+	case ",", ":", ">", ";", "quad", "qquad", " ", "!":
+		em := map[string]float64{",": 3.0 / 18, ":": 4.0 / 18, ">": 4.0 / 18, ";": 5.0 / 18,
+			" ": 1.0 / 3, "quad": 1, "qquad": 2, "!": -3.0 / 18}[name]
+		return &mathBox{width: em * size, class: mathSkip}
+	case "{", "}", "|", "%", "$", "#", "&", "_":
+		class := map[string]mathClass{"{": mathOpen, "}": mathClose}[name]
+		if name == "|" {
+			return p.glyph("||", p.mathFont(), "", size, mathOrd)
+		}
+		return p.glyph(name, p.mathFont(), "", size, class)
+	case "tag":
+		p.tag = p.rawArg()
+		return nil
+	case "notag", "nonumber":
+		p.notag = true
+		return nil
+	case "limits", "nolimits", "displaystyle", "textstyle", "scriptstyle", "big", "Big", "bigg", "Bigg":
+		return nil
+	}
+	p.failf(`unknown command \%v`, name)
+	return p.glyph(`\`+name, p.mathFont(), "", size, mathOrd)
+}
+
+// delimiterName reads the delimiter following \left or \right
+func (p *mathParser) delimiterName() string {
+	tok := p.next()
+	switch tok {
+	case "(", ")", "[", "]", "|", ".", `\{`, `\}`, `\|`, `\langle`, `\rangle`, `\lbrace`, `\rbrace`:
+		return strings.TrimPrefix(tok, `\`)
+	}
+	p.failf("bad delimiter %q", tok)
+	return "."
+}
+
+// scripts attaches a subscript and a superscript to base
+func (p *mathParser) scripts(base, sub, sup *mathBox, st mathStyle) *mathBox {
+	size := st.size()
+	box := &mathBox{class: base.class}
+	if base.limits {
+		// above and below the operator
+		gap := 0.15 * size
+		width := base.width
+		for _, s := range []*mathBox{sub, sup} {
+			if s != nil {
+				width = max(width, s.width)
+			}
+		}
+		box.add(base, (width-base.width)/2, 0)
+		box.width, box.height, box.depth = width, base.height, base.depth
+		if sup != nil {
+			y := base.height + gap + sup.depth
+			box.add(sup, (width-sup.width)/2, y)
+			box.height = y + sup.height
+		}
+		if sub != nil {
+			y := base.depth + gap + sub.height
+			box.add(sub, (width-sub.width)/2, -y)
+			box.depth = y + sub.depth
+		}
+		return box
+	}
+	box.add(base, 0, 0)
+	box.width, box.height, box.depth = base.width, base.height, base.depth
+	supShift := max(base.height-0.3*size, 0.38*size)
+	subShift := max(0.2*size, base.depth+0.05*size)
+	if sup != nil {
+		supShift = max(supShift, sup.depth+0.1*size)
+	}
+	if sub != nil {
+		subShift = max(subShift, sub.height-0.55*size)
+	}
+	if sub != nil && sup != nil {
+		if gap := (supShift - sup.depth) - (sub.height - subShift); gap < 0.15*size {
+			subShift += 0.15*size - gap
+		}
+	}
+	x := base.width
+	if sup != nil {
+		box.add(sup, x, supShift)
+		box.width = max(box.width, x+sup.width)
+		box.height = max(box.height, supShift+sup.height)
+	}
+	if sub != nil {
+		box.add(sub, x, -subShift)
+		box.width = max(box.width, x+sub.width)
+		box.depth = max(box.depth, subShift+sub.depth)
+	}
+	box.width += 0.05 * size
+	return box
+}
+
+// largeOp is a sum, product or integral sign, centered on the axis
+func (p *mathParser) largeOp(name, code string, bottom, top float64, limits, integral bool, st mathStyle) *mathBox {
+	size := st.size()
+	scale := 1.2
+	if st.display {
+		scale = 1.7
+		if integral {
+			scale = 2
+		}
+	}
+	gsize := size * scale
+	box := p.glyph(code, mathSymbolFont, "", gsize, mathOp)
+	dy := st.axis() - (bottom+top)/2*gsize
+	box.glyphs[0].y = dy
+	box.height = top*gsize + dy
+	box.depth = -(bottom*gsize + dy)
+	box.limits = limits && st.display
+	if name == "oint" {
+		c := circle(box.width/2, st.axis(), 0.18*gsize)
+		box.path(mathCurve, st.rule(), c[0:8]...)
+		box.path(mathCurve, st.rule(), c[6:14]...)
+	}
+	return box
+}
+
+// circle returns two cubic curves approximating a circle
+func circle(cx, cy, radius float64) []float64 {
+	k := 0.5523 * radius * 2
+	return []float64{
+		cx - radius, cy, cx - radius, cy + k, cx + radius, cy + k, cx + radius, cy,
+		cx + radius, cy - k, cx - radius, cy - k, cx - radius, cy,
+	}
+}
+
+// fraction sets num over den; the bar is left out for binomials
+func (p *mathParser) fraction(num, den *mathBox, st mathStyle, bar bool) *mathBox {
+	size := st.size()
+	axis, rule := st.axis(), st.rule()
+	gap := 0.12 * size
+	if st.display {
+		gap = 0.18 * size
+	}
+	width := max(num.width, den.width) + 0.2*size
+	numY := axis + rule/2 + gap + num.depth
+	denY := axis - rule/2 - gap - den.height
+	box := &mathBox{class: mathInner, width: width}
+	box.add(num, (width-num.width)/2, numY)
+	box.add(den, (width-den.width)/2, denY)
+	box.height = numY + num.height
+	box.depth = den.depth - denY
+	if bar {
+		box.path(mathLine, rule, 0.05*size, axis, width-0.05*size, axis)
+	}
+	return box
+}
+
+// sqrt draws a radical sign over body
+func (p *mathParser) sqrt(body, index *mathBox, st mathStyle) *mathBox {
+	size := st.size()
+	rule := st.rule()
+	top := body.height + 0.15*size
+	bottom := -body.depth - 0.1*size
+	tick := bottom + 0.45*(top-bottom)
+	box := &mathBox{class: mathOrd}
+	x := 0.0
+	if index != nil {
+		x = max(0, index.width-0.25*size)
+		box.add(index, x+0.28*size-index.width, tick+0.08*size+index.depth)
+		box.height = tick + 0.08*size + index.depth + index.height
+	}
+	sign := 0.55 * size
+	box.path(mathLine, rule,
+		x, tick, x+0.12*size, tick+0.06*size, x+0.3*size, bottom,
+		x+sign, top, x+sign+body.width+0.1*size, top)
+	box.add(body, x+sign+0.05*size, 0)
+	box.width = x + sign + body.width + 0.15*size
+	box.height = max(box.height, top+rule)
+	box.depth = -bottom
+	return box
+}
+
+// accent draws a mark above (or below) body
+func (p *mathParser) accent(name string, body *mathBox, st mathStyle) *mathBox {
+	size := st.size()
+	rule := st.rule()
+	box := &mathBox{class: mathOrd, width: body.width, height: body.height, depth: body.depth}
+	box.add(body, 0, 0)
+	cx := body.width / 2
+	if len(body.glyphs) == 1 && body.glyphs[0].style == "I" {
+		// italic letters lean to the right
+		cx += 0.05 * size
+	}
+	y := max(body.height, 0.46*size) + 0.08*size
+	switch name {
+	case "bar", "overline":
+		box.path(mathLine, rule, 0.05*size, y, body.width, y)
+		box.height = y + rule
+	case "underline":
+		y = -body.depth - 0.1*size
+		box.path(mathLine, rule, 0, y, body.width, y)
+		box.depth = -y + rule
+	case "vec":
+		w := max(body.width, 0.4*size)
+		box.path(mathLine, rule, cx-w/2, y+0.05*size, cx+w/2, y+0.05*size)
+		box.path(mathLine, rule, cx+w/2-0.12*size, y+0.12*size, cx+w/2, y+0.05*size, cx+w/2-0.12*size, y-0.02*size)
+		box.height = y + 0.15*size
+	case "hat", "widehat":
+		w := 0.18 * size
+		if name == "widehat" {
+			w = max(w, body.width/2)
+		}
+		box.path(mathLine, rule, cx-w, y, cx, y+0.12*size, cx+w, y)
+		box.height = y + 0.15*size
+	case "tilde", "widetilde":
+		w := 0.2 * size
+		if name == "widetilde" {
+			w = max(w, body.width/2)
+		}
+		box.path(mathCurve, rule, cx-w, y, cx-w/3, y+0.15*size, cx+w/3, y-0.05*size, cx+w, y+0.1*size)
+		box.height = y + 0.15*size
+	case "dot":
+		box.path(mathDot, 0.05*size, cx, y+0.05*size)
+		box.height = y + 0.1*size
+	case "ddot":
+		box.path(mathDot, 0.05*size, cx-0.1*size, y+0.05*size)
+		box.path(mathDot, 0.05*size, cx+0.1*size, y+0.05*size)
+		box.height = y + 0.1*size
+	}
+	return box
+}
+
+// fence sets body between the two delimiters, sized to its height
+func (p *mathParser) fence(left, right string, body *mathBox, st mathStyle) *mathBox {
+	l := p.delimiter(left, body, st, mathOpen)
+	r := p.delimiter(right, body, st, mathClose)
+	box := &mathBox{class: mathInner}
+	box.add(l, 0, 0)
+	box.add(body, l.width, 0)
+	box.add(r, l.width+body.width, 0)
+	box.width = l.width + body.width + r.width
+	box.height = max(l.height, body.height, r.height)
+	box.depth = max(l.depth, body.depth, r.depth)
+	return box
+}
+
+// delimiter is a bracket tall enough to enclose body; small ones are
+// taken from the font, larger ones are drawn
+func (p *mathParser) delimiter(name string, body *mathBox, st mathStyle, class mathClass) *mathBox {
+	size := st.size()
+	axis := st.axis()
+	half := max(body.height-axis, body.depth+axis) + 0.05*size
+	if name == "." {
+		return &mathBox{class: class, width: 0.1 * size}
+	}
+	if half <= 0.55*size {
+		switch name {
+		case "langle", "rangle":
+			return p.glyph(mathSymbols[name].code, mathSymbolFont, "", size, class)
+		case "lbrace":
+			name = "{"
+		case "rbrace":
+			name = "}"
+		}
+		return p.glyph(name, p.mathFont(), "", size, class)
+	}
+	top, bottom := axis+half, axis-half
+	height := top - bottom
+	w := 0.3*size + 0.04*height
+	rule := st.rule() * 1.2
+	box := &mathBox{class: class, width: w, height: top, depth: -bottom}
+	// drawn as opening delimiters, mirrored for the closing ones
+	var paths []mathPath
+	switch name {
+	case "(", ")":
+		paths = append(paths, mathPath{kind: mathCurve, width: rule, points: []float64{
+			0.85 * w, top, -0.1 * w, top - 0.25*height, -0.1 * w, bottom + 0.25*height, 0.85 * w, bottom}})
+	case "[", "]":
+		paths = append(paths, mathPath{kind: mathLine, width: rule, points: []float64{
+			0.8 * w, top, 0.3 * w, top, 0.3 * w, bottom, 0.8 * w, bottom}})
+	case "{", "}", "lbrace", "rbrace":
+		mid := (top + bottom) / 2
+		paths = append(paths,
+			mathPath{kind: mathCurve, width: rule, points: []float64{
+				0.9 * w, top, 0.3 * w, top, 0.6 * w, mid, 0.1 * w, mid}},
+			mathPath{kind: mathCurve, width: rule, points: []float64{
+				0.1 * w, mid, 0.6 * w, mid, 0.3 * w, bottom, 0.9 * w, bottom}})
+	case "langle", "rangle":
+		paths = append(paths, mathPath{kind: mathLine, width: rule, points: []float64{
+			0.8 * w, top, 0.2 * w, (top + bottom) / 2, 0.8 * w, bottom}})
+	case "|":
+		box.width = 0.25 * size
+		paths = append(paths, mathPath{kind: mathLine, width: rule, points: []float64{
+			box.width / 2, top, box.width / 2, bottom}})
+	case "||":
+		box.width = 0.4 * size
+		for _, x := range []float64{0.3, 0.7} {
+			paths = append(paths, mathPath{kind: mathLine, width: rule, points: []float64{
+				x * box.width, top, x * box.width, bottom}})
+		}
+	}
+	if class == mathClose {
+		for _, path := range paths {
+			for i := 0; i < len(path.points); i += 2 {
+				path.points[i] = box.width - path.points[i]
+			}
+		}
+	}
+	box.paths = paths
+	return box
+}
+
+// environment lays out \begin{name} ... \end{name}
+func (p *mathParser) environment(name string, st mathStyle) *mathBox {
+	box := p.rows(st, name, name)
+	switch name {
+	case "pmatrix":
+		return p.fence("(", ")", box, st)
+	case "bmatrix":
+		return p.fence("[", "]", box, st)
+	case "Bmatrix":
+		return p.fence("{", "}", box, st)
+	case "vmatrix":
+		return p.fence("|", "|", box, st)
+	case "Vmatrix":
+		return p.fence("||", "||", box, st)
+	case "cases":
+		box = p.fence("{", ".", box, st)
+	}
+	return box
+}
+
+// rows reads the cells of an array-like environment, up to \end{end}
+// or to the end of the source if end is empty, and lines them up
+func (p *mathParser) rows(st mathStyle, name, end string) *mathBox {
+	size := st.size()
+	align, colsep := "c", 0.8*size
+	cst := st
+	cst.display = false
+	switch name {
+	case "matrix", "pmatrix", "bmatrix", "Bmatrix", "vmatrix", "Vmatrix", "smallmatrix":
+	case "cases":
+		align = "ll"
+	case "array":
+		align = strings.NewReplacer("|", "", " ", "").Replace(p.rawArg())
+		if align == "" {
+			align = "c"
+		}
+	case "aligned", "align", "align*", "split", "eqnarray":
+		align, colsep = "rl", 0
+		cst.display = st.display
+	case "gathered", "gather", "gather*":
+		cst.display = st.display
+	default:
+		p.failf("unknown environment %v", name)
+	}
+	var rows [][]*mathBox
+	row := []*mathBox{}
+	for p.err == nil {
+		row = append(row, p.list(cst))
+		tok := p.next()
+		if tok == "&" {
+			continue
+		}
+		rows = append(rows, row)
+		row = []*mathBox{}
+		if tok == `\\` {
+			continue
+		}
+		if tok == `\end` {
+			if got := p.rawArg(); got != end {
+				p.failf(`\begin{%v} ended by \end{%v}`, end, got)
+			}
+		} else if tok != "" || end != "" {
+			p.failf(`missing \end{%v}`, end)
+		}
+		break
+	}
+	// a trailing \\ leaves an empty row
+	if n := len(rows); n > 1 && len(rows[n-1]) == 1 && rows[n-1][0].width == 0 {
+		rows = rows[:n-1]
+	}
+	return p.grid(rows, align, colsep, st)
+}
+
+// grid lines up the cells of rows in columns, centering the whole on the axis
+func (p *mathParser) grid(rows [][]*mathBox, align string, colsep float64, st mathStyle) *mathBox {
+	size := st.size()
+	var widths []float64
+	heights := make([]float64, len(rows))
+	depths := make([]float64, len(rows))
+	for i, row := range rows {
+		heights[i], depths[i] = 0.7*size, 0.3*size
+		for j, cell := range row {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], cell.width)
+			heights[i] = max(heights[i], cell.height)
+			depths[i] = max(depths[i], cell.depth)
+		}
+	}
+	rowsep := 0.25 * size
+	total := 0.0
+	for i := range rows {
+		total += heights[i] + depths[i]
+	}
+	total += rowsep * float64(max(len(rows)-1, 0))
+
+	// space between column j and the next one; aligned environments
+	// pair their columns, with no space within a pair
+	gap := func(j int) float64 {
+		if colsep == 0 && j%2 == 0 {
+			return 0
+		}
+		return max(colsep, size)
+	}
+	box := &mathBox{class: mathInner}
+	y := st.axis() + total/2
+	box.height, box.depth = y, total-y
+	for i, row := range rows {
+		y -= heights[i]
+		x := 0.0
+		for j, cell := range row {
+			dx := (widths[j] - cell.width) / 2
+			switch align[j%len(align)] {
+			case 'l':
+				dx = 0
+			case 'r':
+				dx = widths[j] - cell.width
+			}
+			box.add(cell, x+dx, y)
+			x += widths[j] + gap(j)
+		}
+		y -= depths[i] + rowsep
+	}
+	for j, w := range widths {
+		box.width += w
+		if j < len(widths)-1 {
+			box.width += gap(j)
+		}
+	}
+	return box
+}
+
+// typesetMath lays out tex; display selects display style
+func (r *PdfRenderer) typesetMath(tex string, size float64, display bool) (*mathBox, *mathParser) {
+	r.addMathSymbolFont()
+	p := &mathParser{r: r, src: tex}
+	box := p.parse(mathStyle{base: size, display: display})
+	return box, p
+}
+
+// drawMath draws b with the origin of its baseline at (x, y) on the page
+func (r *PdfRenderer) drawMath(b *mathBox, x, y float64) {
+	dr, dg, db := r.Pdf.GetDrawColor()
+	fr, fg, fb := r.Pdf.GetFillColor()
+	lw := r.Pdf.GetLineWidth()
+	c := r.Math.TextColor
+	r.Pdf.SetTextColor(c.Red, c.Green, c.Blue)
+	r.Pdf.SetDrawColor(c.Red, c.Green, c.Blue)
+	r.Pdf.SetFillColor(c.Red, c.Green, c.Blue)
+	for _, g := range b.glyphs {
+		r.Pdf.SetFont(g.font, g.style, g.size)
+		r.Pdf.Text(x+g.x, y-g.y, g.text)
+	}
+	for _, path := range b.paths {
+		pt := path.points
+		switch path.kind {
+		case mathLine:
+			r.Pdf.SetLineWidth(path.width)
+			for i := 2; i+1 < len(pt); i += 2 {
+				r.Pdf.Line(x+pt[i-2], y-pt[i-1], x+pt[i], y-pt[i+1])
+			}
+		case mathCurve:
+			r.Pdf.SetLineWidth(path.width)
+			r.Pdf.CurveBezierCubic(x+pt[0], y-pt[1], x+pt[2], y-pt[3],
+				x+pt[4], y-pt[5], x+pt[6], y-pt[7], "D")
+		case mathDot:
+			r.Pdf.Circle(x+pt[0], y-pt[1], path.width, "F")
+		}
+	}
+	r.Pdf.SetDrawColor(dr, dg, db)
+	r.Pdf.SetFillColor(fr, fg, fb)
+	r.Pdf.SetLineWidth(lw)
+}
@@ -0,0 +1,154 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"encoding/json"
+)
+
+// mathSymbolFont is the family under which the standard PDF Symbol font
+// is registered. fpdf maps its own "symbol" family to ZapfDingbats, so
+// the font is added under a name of our own.
+const mathSymbolFont = "mdtopdfsymbol"
+
+// symbolWidths are the glyph widths of the standard Symbol font,
+// in thousandths of the font size
+var symbolWidths = [256]int{
+	250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250,
+	250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250, 250,
+	250, 333, 713, 500, 549, 833, 778, 439, 333, 333, 500, 549, 250, 549, 250, 278,
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 549, 549, 549, 444,
+	549, 722, 667, 722, 612, 611, 763, 603, 722, 333, 631, 722, 686, 889, 722, 722,
+	768, 741, 556, 592, 611, 690, 439, 768, 645, 795, 611, 333, 863, 333, 658, 500,
+	500, 631, 549, 549, 494, 439, 521, 411, 603, 329, 603, 549, 549, 576, 521, 549,
+	549, 521, 549, 603, 439, 576, 713, 686, 493, 686, 494, 480, 200, 480, 549, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	750, 620, 247, 549, 167, 713, 500, 753, 753, 753, 753, 1042, 987, 603, 987, 603,
+	400, 549, 411, 549, 549, 713, 494, 460, 549, 549, 549, 549, 1000, 603, 1000, 658,
+	823, 686, 795, 987, 768, 768, 823, 768, 768, 713, 713, 713, 713, 713, 713, 713,
+	768, 713, 790, 790, 890, 823, 549, 250, 713, 603, 603, 1042, 987, 603, 987, 603,
+	494, 329, 790, 790, 786, 713, 384, 384, 384, 384, 384, 384, 494, 494, 494, 494,
+	0, 329, 274, 686, 686, 686, 384, 384, 384, 384, 384, 384, 494, 494, 494, 0,
+}
+
+// addMathSymbolFont registers the Symbol font with the document; being
+// one of the standard PDF fonts, it is not embedded
+func (r *PdfRenderer) addMathSymbolFont() {
+	if r.mathFontAdded {
+		return
+	}
+	def, _ := json.Marshal(map[string]any{
+		"Tp": "Core", "Name": "Symbol", "Up": -100, "Ut": 50, "Cw": symbolWidths,
+	})
+	r.Pdf.AddFontFromBytes(mathSymbolFont, "", def, nil)
+	r.mathFontAdded = true
+}
+
+// mathSymbol is a glyph of the Symbol font
+type mathSymbol struct {
+	code  string
+	class mathClass
+}
+
+// mathSymbols maps LaTeX commands to Symbol font glyphs
+var mathSymbols = map[string]mathSymbol{
+	// lowercase Greek
+	"alpha": {"a", mathOrd}, "beta": {"b", mathOrd}, "gamma": {"g", mathOrd},
+	"delta": {"d", mathOrd}, "epsilon": {"e", mathOrd}, "varepsilon": {"e", mathOrd},
+	"zeta": {"z", mathOrd}, "eta": {"h", mathOrd}, "theta": {"q", mathOrd},
+	"vartheta": {"J", mathOrd}, "iota": {"i", mathOrd}, "kappa": {"k", mathOrd},
+	"lambda": {"l", mathOrd}, "mu": {"m", mathOrd}, "nu": {"n", mathOrd},
+	"xi": {"x", mathOrd}, "omicron": {"o", mathOrd}, "pi": {"p", mathOrd},
+	"varpi": {"v", mathOrd}, "rho": {"r", mathOrd}, "sigma": {"s", mathOrd},
+	"varsigma": {"V", mathOrd}, "tau": {"t", mathOrd}, "upsilon": {"u", mathOrd},
+	"phi": {"f", mathOrd}, "varphi": {"j", mathOrd}, "chi": {"c", mathOrd},
+	"psi": {"y", mathOrd}, "omega": {"w", mathOrd},
+	// uppercase Greek
+	"Gamma": {"G", mathOrd}, "Delta": {"D", mathOrd}, "Theta": {"Q", mathOrd},
+	"Lambda": {"L", mathOrd}, "Xi": {"X", mathOrd}, "Pi": {"P", mathOrd},
+	"Sigma": {"S", mathOrd}, "Upsilon": {"\xa1", mathOrd}, "Phi": {"F", mathOrd},
+	"Psi": {"Y", mathOrd}, "Omega": {"W", mathOrd},
+	// ordinary symbols
+	"infty": {"\xa5", mathOrd}, "partial": {"\xb6", mathOrd}, "nabla": {"\xd1", mathOrd},
+	"forall": {"\x22", mathOrd}, "exists": {"\x24", mathOrd}, "emptyset": {"\xc6", mathOrd},
+	"varnothing": {"\xc6", mathOrd}, "aleph": {"\xc0", mathOrd}, "angle": {"\xd0", mathOrd},
+	"prime": {"\xa2", mathOrd}, "neg": {"\xd8", mathOrd}, "lnot": {"\xd8", mathOrd},
+	"ldots": {"\xbc", mathInner}, "dots": {"\xbc", mathInner},
+	"cdots": {"\xd7\xd7\xd7", mathInner}, "therefore": {"\x5c", mathOrd},
+	"Re": {"\xc2", mathOrd}, "Im": {"\xc1", mathOrd}, "wp": {"\xc3", mathOrd},
+	// binary operators
+	"pm": {"\xb1", mathBin}, "times": {"\xb4", mathBin}, "div": {"\xb8", mathBin},
+	"cdot": {"\xd7", mathBin}, "ast": {"\x2a", mathBin}, "cup": {"\xc8", mathBin},
+	"cap": {"\xc7", mathBin}, "wedge": {"\xd9", mathBin}, "land": {"\xd9", mathBin},
+	"vee": {"\xda", mathBin}, "lor": {"\xda", mathBin}, "oplus": {"\xc5", mathBin},
+	"otimes": {"\xc4", mathBin}, "bullet": {"\xb7", mathBin}, "circ": {"\xb0", mathBin},
+	// relations
+	"le": {"\xa3", mathRel}, "leq": {"\xa3", mathRel}, "ge": {"\xb3", mathRel},
+	"geq": {"\xb3", mathRel}, "ne": {"\xb9", mathRel}, "neq": {"\xb9", mathRel},
+	"approx": {"\xbb", mathRel}, "equiv": {"\xba", mathRel}, "sim": {"\x7e", mathRel},
+	"cong": {"\x40", mathRel}, "propto": {"\xb5", mathRel}, "to": {"\xae", mathRel},
+	"rightarrow": {"\xae", mathRel}, "leftarrow": {"\xac", mathRel}, "gets": {"\xac", mathRel},
+	"leftrightarrow": {"\xab", mathRel}, "Rightarrow": {"\xde", mathRel},
+	"implies": {"\xde", mathRel}, "Leftarrow": {"\xdc", mathRel},
+	"Leftrightarrow": {"\xdb", mathRel}, "iff": {"\xdb", mathRel},
+	"uparrow": {"\xad", mathRel}, "downarrow": {"\xaf", mathRel}, "mapsto": {"\xae", mathRel},
+	"in": {"\xce", mathRel}, "notin": {"\xcf", mathRel}, "ni": {"\x27", mathRel},
+	"subset": {"\xcc", mathRel}, "subseteq": {"\xcd", mathRel}, "supset": {"\xc9", mathRel},
+	"supseteq": {"\xca", mathRel}, "perp": {"\x5e", mathRel}, "mid": {"|", mathRel},
+	// delimiters
+	"langle": {"\xe1", mathOpen}, "rangle": {"\xf1", mathClose},
+}
+
+// mathLargeOps are the operators drawn larger in display style; glyphs
+// are vertically centered on the math axis using the glyph extents
+// (bottom, top), in thousandths of the font size
+var mathLargeOps = map[string]struct {
+	code        string
+	bottom, top float64
+	limits      bool // limits go above and below in display style
+	integral    bool
+}{
+	"sum":    {"\xe5", -108, 752, true, false},
+	"prod":   {"\xd5", -101, 752, true, false},
+	"bigcup": {"\xc8", -15, 702, true, false},
+	"bigcap": {"\xc7", -15, 702, true, false},
+	"int":    {"\xf2", -107, 916, false, true},
+	"iint":   {"\xf2\xf2", -107, 916, false, true},
+	"iiint":  {"\xf2\xf2\xf2", -107, 916, false, true},
+	"oint":   {"\xf2", -107, 916, false, true},
+}
+
+// mathFunctions are the operator names set in upright type; those marked
+// true take limits above and below in display style
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false,
+	"tanh": false, "coth": false, "log": false, "ln": false, "lg": false, "exp": false,
+	"det": true, "dim": false, "ker": false, "deg": false, "gcd": true, "arg": false,
+	"hom": false, "Pr": true, "lim": true, "liminf": true, "limsup": true,
+	"max": true, "min": true, "sup": true, "inf": true,
+}
+
+// mathSpacing is TeX's table of the space inserted between adjacent atoms,
+// indexed by the class of the left and of the right atom: 1 is a thin space,
+// 2 a medium and 3 a thick one. Negative values only apply to display and
+// text styles, not to scripts.
+var mathSpacing = [8][8]int{
+	// ord, op, bin, rel, open, close, punct, inner
+	{0, 1, -2, -3, 0, 0, 0, -1},     // ord
+	{1, 1, 0, -3, 0, 0, 0, -1},      // op
+	{-2, -2, 0, 0, -2, 0, 0, -2},    // bin
+	{-3, -3, 0, 0, -3, 0, 0, -3},    // rel
+	{0, 0, 0, 0, 0, 0, 0, 0},        // open
+	{0, 1, -2, -3, 0, 0, 0, -1},     // close
+	{-1, -1, 0, -1, -1, -1, -1, -1}, // punct
+	{-1, 1, -2, -3, -1, 0, -1, -1},  // inner
+}
@@ -0,0 +1,91 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// prepareMatter returns the letters of the top level headings of the back
+// matter (see parser.Mmark), which are labelled "Appendix A. ",
+// "Appendix B. " and so on
+func prepareMatter(doc ast.Node) map[*ast.Heading]string {
+	matter := ast.DocumentMatterNone
+	var appendices []*ast.Heading
+	top := 7
+	for _, c := range doc.GetChildren() {
+		switch c := c.(type) {
+		case *ast.DocumentMatter:
+			matter = c.Matter
+		case *ast.Heading:
+			if matter == ast.DocumentMatterBack && !c.IsTitleblock && c.Level <= top {
+				if c.Level < top {
+					top, appendices = c.Level, nil
+				}
+				appendices = append(appendices, c)
+			}
+		}
+	}
+	lettered := make(map[*ast.Heading]string)
+	for i, h := range appendices {
+		lettered[h] = letters(i + 1)
+	}
+	return lettered
+}
+
+// processDocumentMatter starts the front, main or back matter on a page of
+// its own. The front matter is numbered in Roman numerals and the main
+// matter from 1 again; the back matter goes on from the main matter.
+func (r *PdfRenderer) processDocumentMatter(node *ast.DocumentMatter) {
+	r.tracer("DocumentMatter", matterNames[node.Matter])
+	if node.Matter == r.documentMatter {
+		return
+	}
+	if r.Pdf.GetY() > r.mtop+0.01 {
+		r.addPage()
+	}
+	switch node.Matter {
+	case ast.DocumentMatterFront:
+		r.restartPageNumbering(true)
+	case ast.DocumentMatterMain:
+		r.restartPageNumbering(false)
+	}
+	r.documentMatter = node.Matter
+}
+
+var matterNames = map[ast.DocumentMatters]string{
+	ast.DocumentMatterFront: "{frontmatter}",
+	ast.DocumentMatterMain:  "{mainmatter}",
+	ast.DocumentMatterBack:  "{backmatter}",
+}
+
+// roman returns n in lowercase Roman numerals
+func roman(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var b strings.Builder
+	for i, v := range values {
+		for ; n >= v; n -= v {
+			b.WriteString(numerals[i])
+		}
+	}
+	return b.String()
+}
+
+// letters returns n as uppercase letters: A to Z, then AA, AB and so on
+func letters(n int) string {
+	s := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		s = string(rune('A'+(n-1)%26)) + s
+	}
+	return s
+}
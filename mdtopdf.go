@@ -23,12 +23,13 @@ package mdtopdf
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 
 	"strings"
+	"time"
 
 	"codeberg.org/go-pdf/fpdf"
 	"github.com/gomarkdown/markdown"
@@ -77,12 +78,16 @@ type PdfRenderer struct {
 	// prior to processing the markdown source
 	Pdf                *fpdf.Fpdf
 	orientation, units string
+	metadata           Metadata
 	papersize, fontdir string
 
 	// trace/log file if present
 	pdfFile, tracerFile string
 	w                   *bufio.Writer
 
+	// optional writers used instead of pdfFile and tracerFile
+	output, tracerOutput io.Writer
+
 	// default margins for safe keeping
 	mleft, mtop, mright, mbottom float64
 
@@ -112,9 +117,66 @@ type PdfRenderer struct {
 	// Table styling
 	THeader Styler
 	TBody   Styler
+	// written above the part of a table carried over to a new page
+	TableContinuedCaption string
+
+	// definition list terms
+	DefinitionTerm Styler
+
+	// task list checkboxes; TextColor is used for the
+	// border and the tick, FillColor for the box
+	Checkbox Styler
+
+	// math (parser.MathJax); Font is used for letters and digits,
+	// Greek letters and symbols come from the Symbol font
+	Math Styler
+	// number display math
+	MathNumbering bool
+	mathNumber    int
+	mathFontAdded bool
+
+	// diagrams drawn from fenced code blocks; TextColor is used for
+	// the text and the lines, FillColor for the boxes
+	Diagram Styler
+
+	// handlers of fenced code blocks, keyed by language
+	codeBlocks map[string]CodeBlockHandler
+
+	// renderers used in place of the built-in ones, and the parser
+	// hook which may produce nodes of their own
+	nodeRenderers []NodeRenderer
+	parserHook    parser.BlockFunc
+
+	// bookmarks made from the headings
+	Outline OutlineOptions
+	outline outlineState
+
+	// keep-with-next, widow and orphan control
+	Pagination PaginationOptions
+	pagination paginationState
+
+	// heading numbers, and the headings links go to by ID
+	Numbering     NumberingOptions
+	refs          map[string]*headingRef
+	headingLabels map[*ast.Heading]string
+
+	// table of contents
+	toc tocState
+
+	// page headers and footers
+	HeaderFooter Styler
+	pages        pageState
+
+	// footnote text
+	Footnote     Styler
+	FootnoteMode FootnoteMode
+	fn           footnoteState
 
 	cs states
 
+	// state of the table being rendered
+	tbl tableState
+
 	// code styling
 	Code Styler
 
@@ -129,8 +191,18 @@ type PdfRenderer struct {
 	documentMatter            ast.DocumentMatters // keep track of front/main/back matter.
 	Extensions                parser.Extensions
 	ColumnWidths              map[ast.Node][]float64
+	columnMinWidths           map[ast.Node][]float64
 
 	tocLinks map[string]*int
+
+	// text of the task list item being written, starting with its marker
+	taskText *ast.Text
+
+	// StrictErrors makes the first render error abort processing;
+	// otherwise errors are collected as warnings
+	StrictErrors bool
+	err          error
+	warnings     []error
 }
 
 // TOCEntry represents a table of contents entry
@@ -269,6 +341,30 @@ func (r *PdfRenderer) SetLightTheme() {
 	// Table Body Text
 	r.TBody = Styler{Font: "Arial", Style: "", Size: 12, Spacing: 2,
 		TextColor: Colorlookup("black"), FillColor: Color{240, 240, 240}}
+
+	// Footnote Text
+	r.Footnote = Styler{Font: "Arial", Style: "", Size: 9, Spacing: 2,
+		TextColor: Colorlookup("black"), FillColor: Colorlookup("white")}
+
+	// Task list checkboxes
+	r.Checkbox = Styler{Font: "Arial", Style: "", Size: 12, Spacing: 2,
+		TextColor: Colorlookup("dimgray"), FillColor: Colorlookup("white")}
+
+	// Definition list terms
+	r.DefinitionTerm = Styler{Font: "Arial", Style: "b", Size: 12, Spacing: 2,
+		TextColor: Colorlookup("black"), FillColor: Colorlookup("white")}
+
+	// Math
+	r.Math = Styler{Font: "Times", Style: "", Size: 12, Spacing: 2,
+		TextColor: Colorlookup("black"), FillColor: Colorlookup("white")}
+
+	// Diagrams
+	r.Diagram = Styler{Font: "Arial", Style: "", Size: 10, Spacing: 2,
+		TextColor: Colorlookup("black"), FillColor: Color{240, 240, 240}}
+
+	// Page headers and footers
+	r.HeaderFooter = Styler{Font: "Arial", Style: "i", Size: 8, Spacing: 2,
+		TextColor: Colorlookup("gray"), FillColor: Colorlookup("white")}
 }
 
 // SetDarkTheme sets theme to 'dark'
@@ -320,21 +416,62 @@ func (r *PdfRenderer) SetDarkTheme() {
 	r.TBody = Styler{Font: "Arial", Style: "", Size: 12, Spacing: 2,
 		FillColor: Color{200, 200, 200}, TextColor: Color{128, 128, 128}}
 
+	// Footnote Text
+	r.Footnote = Styler{Font: "Arial", Style: "", Size: 9, Spacing: 2,
+		FillColor: Colorlookup("black"), TextColor: Colorlookup("darkgray")}
+
+	// Task list checkboxes
+	r.Checkbox = Styler{Font: "Arial", Style: "", Size: 12, Spacing: 2,
+		FillColor: Color{32, 35, 37}, TextColor: Colorlookup("darkgray")}
+
+	// Definition list terms
+	r.DefinitionTerm = Styler{Font: "Arial", Style: "b", Size: 12, Spacing: 2,
+		FillColor: Colorlookup("black"), TextColor: Colorlookup("white")}
+
+	// Math
+	r.Math = Styler{Font: "Times", Style: "", Size: 12, Spacing: 2,
+		FillColor: Colorlookup("black"), TextColor: Colorlookup("white")}
+
+	// Diagrams
+	r.Diagram = Styler{Font: "Arial", Style: "", Size: 10, Spacing: 2,
+		FillColor: Color{32, 35, 37}, TextColor: Colorlookup("white")}
+
+	// Page headers and footers
+	r.HeaderFooter = Styler{Font: "Arial", Style: "i", Size: 8, Spacing: 2,
+		FillColor: Colorlookup("black"), TextColor: Colorlookup("darkgray")}
 }
 
-// SetCustomTheme sets a custom theme based on JSON config
+// SetCustomTheme sets a custom theme based on JSON config; an error
+// reading it is reported as the render errors are (see LoadCustomTheme)
 func (r *PdfRenderer) SetCustomTheme(themeJSONFile string) {
+	if err := r.LoadCustomTheme(themeJSONFile); err != nil {
+		r.fail(err)
+	}
+}
+
+// LoadCustomTheme sets a custom theme based on JSON config, returning a
+// *ThemeError if the file cannot be read or parsed
+func (r *PdfRenderer) LoadCustomTheme(themeJSONFile string) error {
 
 	config, err := os.ReadFile(themeJSONFile)
 	if err != nil {
-		log.Fatal(err)
+		return &ThemeError{File: themeJSONFile, Err: err}
 	}
 	// Fill the instance from the JSON file content
 	err = json.Unmarshal(config, &r)
 	// Check if is there any error while filling the instance
 	if err != nil {
-		log.Fatal("Error parsing ", themeJSONFile, ":\n", err)
+		themeErr := &ThemeError{File: themeJSONFile, Err: err}
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			themeErr.Offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			themeErr.Offset = typeErr.Offset
+		}
+		return themeErr
 	}
+	return nil
 }
 
 // PdfRendererParams struct to hold params passed to NewPdfRenderer
@@ -343,6 +480,12 @@ type PdfRendererParams struct {
 	Opts                                                          []RenderOption
 	Theme                                                         Theme
 	CustomThemeFile                                               string
+	// Output, if set, receives the generated PDF instead of PdfFile
+	Output io.Writer
+	// TracerOutput, if set, receives the trace log instead of TracerFile
+	TracerOutput io.Writer
+	// Metadata is the document information stored in the PDF
+	Metadata Metadata
 }
 
 // NewPdfRenderer creates and configures an PdfRenderer object,
@@ -354,6 +497,8 @@ func NewPdfRenderer(params PdfRendererParams) *PdfRenderer {
 	// set filenames
 	r.pdfFile = params.PdfFile
 	r.tracerFile = params.TracerFile
+	r.output = params.Output
+	r.tracerOutput = params.TracerOutput
 
 	// Global things
 	r.orientation = "portrait"
@@ -372,11 +517,19 @@ func NewPdfRenderer(params PdfRendererParams) *PdfRenderer {
 	r.Theme = params.Theme
 
 	r.Pdf = fpdf.New(r.orientation, r.units, r.papersize, r.fontdir)
+	r.SetMetadata(params.Metadata)
 
 	r.Pdf.SetHeaderFunc(func() {
 		r.SetPageBackground("", r.BackgroundColor)
+		r.startFootnotePage()
+	})
+	r.Pdf.SetAcceptPageBreakFunc(func() bool {
+		r.flushFootnotes()
+		r.restorePageBreak()
+		return true
 	})
 
+	var themeErr error
 	switch r.Theme {
 	case DARK:
 		r.SetDarkTheme()
@@ -384,9 +537,41 @@ func NewPdfRenderer(params PdfRendererParams) *PdfRenderer {
 		r.SetLightTheme()
 	case CUSTOM:
 		if params.CustomThemeFile != "" {
-			r.SetCustomTheme(params.CustomThemeFile)
+			themeErr = r.LoadCustomTheme(params.CustomThemeFile)
+			if themeErr != nil {
+				// fall back to a usable theme
+				r.SetLightTheme()
+			}
 		}
 	}
+	// custom themes may not define these
+	if r.Footnote.Size == 0 {
+		r.Footnote = r.Normal
+		r.Footnote.Size = 9
+	}
+	if r.Checkbox.Size == 0 {
+		r.Checkbox = r.Normal
+	}
+	if r.DefinitionTerm.Size == 0 {
+		r.DefinitionTerm = r.Normal
+		r.DefinitionTerm.Style = "b"
+	}
+	if r.Math.Size == 0 {
+		r.Math = r.Normal
+		r.Math.Font = "Times"
+	}
+	if r.Diagram.Size == 0 {
+		r.Diagram = r.Normal
+		r.Diagram.Size = 10
+		r.Diagram.FillColor = r.BackgroundColor
+	}
+	if r.HeaderFooter.Size == 0 {
+		r.HeaderFooter = r.Normal
+		r.HeaderFooter.Style = "i"
+		r.HeaderFooter.Size = 8
+	}
+	r.codeBlocks = defaultCodeBlockHandlers()
+	r.Outline = OutlineOptions{MaxLevel: 6}
 	r.Pdf.AddPage()
 	// set default font
 	r.setStyler(r.Normal)
@@ -404,6 +589,10 @@ func NewPdfRenderer(params PdfRendererParams) *PdfRenderer {
 		o(r)
 	}
 
+	if themeErr != nil {
+		r.fail(themeErr)
+	}
+
 	return r
 }
 
@@ -428,11 +617,28 @@ func NewPdfRendererWithDefaultStyler(orient, papersz, pdfFile, tracerFile string
 
 // Process takes the markdown content, parses it to generate the PDF
 func (r *PdfRenderer) Process(content []byte) error {
+	if r.output != nil {
+		return r.ProcessTo(r.output, content)
+	}
+	return r.process(content, r.pdfFile, func() error {
+		return r.Pdf.OutputFileAndClose(r.pdfFile)
+	})
+}
+
+// ProcessTo takes the markdown content, parses it and writes the generated PDF to w
+func (r *PdfRenderer) ProcessTo(w io.Writer, content []byte) error {
+	return r.process(content, "output", func() error {
+		return r.Pdf.Output(w)
+	})
+}
+
+func (r *PdfRenderer) process(content []byte, name string, output func() error) error {
 	// try to open tracer
-	var f *os.File
-	var err error
-	if r.tracerFile != "" {
-		f, err = os.Create(r.tracerFile)
+	if r.tracerOutput != nil {
+		r.w = bufio.NewWriter(r.tracerOutput)
+		defer r.w.Flush()
+	} else if r.tracerFile != "" {
+		f, err := os.Create(r.tracerFile)
 		if err != nil {
 			return fmt.Errorf("os.Create() on tracefile error:%v", err)
 		}
@@ -441,89 +647,63 @@ func (r *PdfRenderer) Process(content []byte) error {
 		defer r.w.Flush()
 	}
 
-	err = r.Run(content)
+	err := r.Run(content)
 	if err != nil {
-		return fmt.Errorf("error on %v:%v", r.pdfFile, err)
+		return fmt.Errorf("error on %v: %w", name, err)
 	}
 
-	err = r.Pdf.OutputFileAndClose(r.pdfFile)
+	err = output()
 	if err != nil {
-		return fmt.Errorf("error on %v:%v", r.pdfFile, err)
+		return fmt.Errorf("error on %v: %w", name, err)
 	}
 
 	return nil
 }
 
 // Run takes the markdown content, parses it but don't generate the PDF. you can access the PDF with youRenderer.Pdf
+//
+// Front matter at the start of content is removed but not applied, as the
+// options it holds are those of NewPdfRenderer: to apply them, split it
+// off with ParseFrontMatter and pass it to NewPdfRenderer with
+// FrontMatter.Apply.
 func (r *PdfRenderer) Run(content []byte) error {
 	// Preprocess content by changing all CRLF to LF
 	s := content
 	s = markdown.NormalizeNewlines(s)
+	// front matter holds options, not text
+	if _, body, err := ParseFrontMatter(s); err != nil {
+		r.fail(err)
+	} else {
+		s = body
+	}
 
 	if r.unicodeTranslator != nil {
 		s = []byte(r.unicodeTranslator(string(s)))
 	}
 
+	if r.err != nil {
+		return r.err
+	}
+
 	p := parser.NewWithExtensions(r.Extensions)
+	p.Opts.ParserHook = r.parserHook
 	doc := markdown.Parse(s, p)
 
+	r.prepareHeadings(doc, prepareMatter(doc))
+	if r.toc.enabled {
+		r.prepareTOC(doc)
+	}
+	// the widths of the tables of an earlier document aren't needed
+	r.ColumnWidths = map[ast.Node][]float64{}
+	r.columnMinWidths = map[ast.Node][]float64{}
 	setColumnWidths(doc, r)
 	_ = markdown.Render(doc, r)
+	if r.toc.enabled {
+		r.finishTOC()
+	}
+	r.finishPages()
 
-	return nil
-}
-
-// Parses all tables and sets the column width to the longest string in that column
-func setColumnWidths(doc ast.Node, r *PdfRenderer) {
-	columnWidths := map[ast.Node][]float64{}
-	intable := false
-	inheader := true
-	cellnum := 0
-	lengths := []float64{}
-	textlength := float64(0)
-	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
-		switch n := node.(type) {
-		case *ast.Table:
-			if entering {
-				intable = true
-			} else {
-				intable = false
-				columnWidths[node] = lengths
-			}
-
-		case *ast.TableHeader:
-			inheader = entering
-			if entering {
-				lengths = []float64{}
-			}
-		case *ast.TableRow:
-			if entering {
-				cellnum = 0
-			}
-		case *ast.TableCell:
-			if entering {
-				if inheader {
-					lengths = append(lengths, 0)
-				}
-			} else {
-				textlength += textlength * 0.2
-
-				currentMax := lengths[cellnum]
-				if textlength > currentMax {
-					lengths[cellnum] = textlength
-				}
-				textlength = 0
-				cellnum++
-			}
-		case *ast.Text:
-			if entering && intable {
-				l := r.Pdf.GetStringWidth(string(n.Literal))
-				textlength += l
-			}
-		}
-		return ast.GoToNext
-	})
-	r.ColumnWidths = columnWidths
+	return r.err
 }
 
 // UpdateParagraphStyler - update with default styler
@@ -567,7 +747,17 @@ func (r *PdfRenderer) multiCell(s Styler, t string) {
 }
 
 func (r *PdfRenderer) writeLink(s Styler, display, url string) {
-	r.Pdf.WriteLinkString(s.Size+s.Spacing, display, url)
+	r.writeLinkString(s.Size+s.Spacing, display, url)
+}
+
+// writeLinkString writes a link to url or, for "#id", to the heading with
+// that ID
+func (r *PdfRenderer) writeLinkString(h float64, display, url string) {
+	if ref := r.headingRef(url); ref != nil {
+		r.Pdf.WriteLinkID(h, display, ref.link)
+		return
+	}
+	r.Pdf.WriteLinkString(h, display, url)
 }
 
 // RenderNode is a default renderer of a single node of a syntax tree. For
@@ -582,6 +772,15 @@ func (r *PdfRenderer) writeLink(s Styler, display, url string) {
 // traversal to the next node.
 // (above taken verbatim from the blackfriday v2 package)
 func (r *PdfRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.err != nil {
+		return ast.Terminate
+	}
+	if status, ok := r.renderHooked(node, entering); ok {
+		if r.err != nil {
+			return ast.Terminate
+		}
+		return status
+	}
 	switch node := node.(type) {
 	case *ast.Text:
 		r.processText(node)
@@ -596,15 +795,15 @@ func (r *PdfRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.
 	case *ast.Strong:
 		r.processStrong(node, entering)
 	case *ast.Del:
-		if entering {
-			r.tracer("DEL (entering)", "Not handled")
-		} else {
-			r.tracer("DEL (leaving)", "Not handled")
-		}
+		r.processDel(node, entering)
 	case *ast.HTMLSpan:
 		r.tracer("HTMLSpan", "Not handled")
 	case *ast.Link:
-		r.processLink(*node, entering)
+		if node.NoteID > 0 {
+			r.processFootnoteRef(node, entering)
+		} else {
+			r.processLink(*node, entering)
+		}
 	case *ast.Image:
 		r.processImage(*node, entering)
 	case *ast.Code:
@@ -618,10 +817,16 @@ func (r *PdfRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.
 	case *ast.HTMLBlock:
 		r.processHTMLBlock(node)
 	case *ast.Heading:
-		r.processHeading(*node, entering)
+		r.processHeading(node, entering)
 	case *ast.HorizontalRule:
 		r.processHorizontalRule(node)
+	case *ast.Footnotes:
+		r.processFootnotes(node, entering)
 	case *ast.List:
+		if node.IsFootnotesList && r.FootnoteMode == FootnotesPageBottom {
+			// the notes were placed at the bottom of the pages
+			return ast.SkipChildren
+		}
 		r.processList(*node, entering)
 	case *ast.ListItem:
 		r.processItem(*node, entering)
@@ -637,10 +842,29 @@ func (r *PdfRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.
 		r.processTableRow(node, entering)
 	case *ast.TableCell:
 		r.processTableCell(*node, entering)
-	/*case *ast.Math:
-	r.processMath(node)*/
+	case *ast.Math:
+		r.processMath(node)
+	case *ast.MathBlock:
+		if entering {
+			r.processMathBlock(node)
+		}
+	case *ast.DocumentMatter:
+		if entering {
+			r.processDocumentMatter(node)
+		}
+	case *ast.CrossReference:
+		if entering {
+			r.processCrossReference(node)
+		}
+	case *tocMarker:
+		r.processTOC(node)
 	default:
-		fmt.Printf("Unknown node type: %T. Skipping\n", node)
+		if entering {
+			r.fail(&UnsupportedNodeError{Node: node})
+		}
+	}
+	if r.err != nil {
+		return ast.Terminate
 	}
 	return ast.GoToNext
 }
@@ -650,8 +874,9 @@ func (r *PdfRenderer) RenderHeader(w io.Writer, ast ast.Node) {
 	r.tracer("RenderHeader", "Not handled")
 }
 
-// RenderFooter is not supported.
+// RenderFooter places the footnotes still pending at the end of the document.
 func (r *PdfRenderer) RenderFooter(w io.Writer, _ ast.Node) {
+	r.flushAllFootnotes()
 }
 
 func (r *PdfRenderer) cr() {
@@ -660,9 +885,15 @@ func (r *PdfRenderer) cr() {
 	r.write(r.cs.peek().textStyle, "\n")
 }
 
+// addPage starts a new page, placing the pending footnotes on the current one first
+func (r *PdfRenderer) addPage() {
+	r.flushFootnotes()
+	r.Pdf.AddPage()
+}
+
 // Tracer traces parse and pdf generation activity.
 func (r *PdfRenderer) tracer(source, msg string) {
-	if r.tracerFile != "" {
+	if r.w != nil {
 		indent := strings.Repeat("-", len(r.cs.stack)-1)
 		r.w.WriteString(fmt.Sprintf("%v[%v] %v\n", indent, source, msg))
 	}
@@ -675,6 +906,11 @@ func dorect(doc *fpdf.Fpdf, x, y, w, h float64, color Color) {
 
 // SetPageBackground - sets background colour of page. String IDs ("blue", "grey", etc) and `Color` structs are both supported
 func (r *PdfRenderer) SetPageBackground(colorStr string, color Color) {
+	// before the first page is added, fpdf would write the rectangle
+	// ahead of the PDF header; the header func paints every page anyway
+	if r.Pdf.PageCount() == 0 {
+		return
+	}
 	w, h := r.Pdf.GetPageSize()
 	if colorStr != "" {
 		color = Colorlookup(colorStr)
@@ -691,6 +927,148 @@ func WithUnicodeTranslator(cp string) RenderOption {
 	}
 }
 
+// WithStrictErrors if true, rendering stops at the first error (missing image,
+// unsupported node, bad theme) and Process returns it. Otherwise errors are
+// collected, see Warnings(), and rendering continues.
+func WithStrictErrors(value bool) RenderOption {
+	return func(r *PdfRenderer) {
+		r.StrictErrors = value
+	}
+}
+
+// WithFootnoteMode sets where footnotes are rendered: at the bottom of the
+// referencing page (the default) or as endnotes.
+func WithFootnoteMode(mode FootnoteMode) RenderOption {
+	return func(r *PdfRenderer) {
+		r.FootnoteMode = mode
+	}
+}
+
+// WithTableContinuedCaption sets a caption, e.g. "(continued)", written
+// above the rest of a table which is carried over to a new page.
+func WithTableContinuedCaption(caption string) RenderOption {
+	return func(r *PdfRenderer) {
+		r.TableContinuedCaption = caption
+	}
+}
+
+// WithMathNumbering if true, display math ($$...$$) is numbered (1), (2)...
+// on the right margin. \tag{...} sets a label of its own and \notag skips one.
+func WithMathNumbering(value bool) RenderOption {
+	return func(r *PdfRenderer) {
+		r.MathNumbering = value
+	}
+}
+
+// WithReproducible makes the output depend on the markdown only, so that
+// the same source yields a byte-identical PDF: the creation and modification
+// dates are set to date (the Unix epoch if zero), resources are written in a
+// fixed order and converted SVG images are named after their content.
+func WithReproducible(date time.Time) RenderOption {
+	return func(r *PdfRenderer) {
+		if date.IsZero() {
+			date = time.Unix(0, 0).UTC()
+		}
+		r.Pdf.SetCatalogSort(true)
+		m := r.metadata
+		m.CreationDate, m.ModificationDate = date, date
+		r.SetMetadata(m)
+	}
+}
+
+// WithOutline sets which headings are bookmarked in the PDF outline; by
+// default, all of them are.
+func WithOutline(opts OutlineOptions) RenderOption {
+	return func(r *PdfRenderer) {
+		r.Outline = opts
+	}
+}
+
+// WithPagination sets how the page breaks are placed; by default, the page
+// breaks after any line. PaginationOptions{KeepWithNext: true, Orphans: 2,
+// Widows: 2} keeps headings with the block after them and leaves at least
+// two lines of a paragraph at the bottom and top of a page.
+func WithPagination(opts PaginationOptions) RenderOption {
+	return func(r *PdfRenderer) {
+		r.Pagination = opts
+	}
+}
+
+// WithHeadingNumbering numbers the headings from opts.StartLevel (H1 if
+// 0) on, e.g. "1", "1.1" and "1.1.1"; the numbers show in the table of
+// contents, the outline and cross-references as well.
+func WithHeadingNumbering(opts NumberingOptions) RenderOption {
+	return func(r *PdfRenderer) {
+		if opts.StartLevel == 0 {
+			opts.StartLevel = 1
+		}
+		r.Numbering = opts
+	}
+}
+
+// WithTableOfContents adds a table of contents listing the headings with
+// their page numbers, in place of a [TOC] paragraph or, if there is none,
+// on a page of its own at the start of the document.
+func WithTableOfContents(opts TOCOptions) RenderOption {
+	return func(r *PdfRenderer) {
+		r.toc.enabled = true
+		r.toc.opts = opts
+	}
+}
+
+// WithHeader writes t at the top of the pages
+func WithHeader(t PageTemplate) RenderOption {
+	return func(r *PdfRenderer) {
+		r.pages.header = &t
+	}
+}
+
+// WithFooter writes t at the bottom of the pages
+func WithFooter(t PageTemplate) RenderOption {
+	return func(r *PdfRenderer) {
+		r.pages.footer = &t
+	}
+}
+
+// WithDiagramRenderer registers the renderer of fenced code blocks whose
+// info string is info, e.g. "mermaid"; it replaces the built-in one if any.
+// A nil renderer prints such blocks as code.
+func WithDiagramRenderer(info string, renderer DiagramRenderer) RenderOption {
+	return func(r *PdfRenderer) {
+		r.codeBlocks[info] = nil
+		if renderer != nil {
+			r.codeBlocks[info] = diagramHandler(renderer)
+		}
+	}
+}
+
+// WithCodeBlockHandler registers the handler of fenced code blocks whose
+// language (the first word of the info string) is lang, e.g. "csv"; it
+// replaces the built-in one if any. A nil handler prints such blocks as code.
+func WithCodeBlockHandler(lang string, handler CodeBlockHandler) RenderOption {
+	return func(r *PdfRenderer) {
+		r.codeBlocks[lang] = handler
+	}
+}
+
+// WithNodeRenderer registers a renderer consulted before the built-in one
+// for every node of the syntax tree; renderers registered later are
+// consulted first.
+func WithNodeRenderer(renderer NodeRenderer) RenderOption {
+	return func(r *PdfRenderer) {
+		r.nodeRenderers = append(r.nodeRenderers, renderer)
+	}
+}
+
+// WithParserHook sets the parser hook (see parser.Options) called on each
+// block of the markdown source; the nodes it returns can be rendered with
+// WithNodeRenderer.
+func WithParserHook(hook parser.BlockFunc) RenderOption {
+	return func(r *PdfRenderer) {
+		r.parserHook = hook
+	}
+}
+
 // IsHorizontalRuleNewPage if true, will start a new page when encountering a HR (---). Useful for presentations.
 func IsHorizontalRuleNewPage(value bool) RenderOption {
 	return func(r *PdfRenderer) {
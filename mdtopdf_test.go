@@ -20,11 +20,24 @@
 package mdtopdf
 
 import (
+	"bytes"
+	"errors"
+	"github.com/canhlinh/svg2png"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/parser"
+	"image"
+	"image/png"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func testit(inputf string, gohighlight bool, t *testing.T) {
@@ -58,7 +71,7 @@ func testit(inputf string, gohighlight bool, t *testing.T) {
 		FontName:        "",
 	}
 	r := NewPdfRenderer(params)
-	r.Extensions = parser.NoIntraEmphasis | parser.Tables | parser.FencedCode | parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists
+	r.Extensions = parser.NoIntraEmphasis | parser.Tables | parser.FencedCode | parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists | parser.Footnotes
 	err = r.Process(content)
 	if err != nil {
 		t.Error(err)
@@ -69,6 +82,50 @@ func TestTables(t *testing.T) {
 	testit("Tables.text", false, t)
 }
 
+func TestWideTables(t *testing.T) {
+	testit("Wide tables.text", false, t)
+}
+
+func TestTableAlignment(t *testing.T) {
+	testit("Table alignment.text", false, t)
+}
+
+func TestTableFormatting(t *testing.T) {
+	testit("Table formatting.text", false, t)
+}
+
+func TestLongTables(t *testing.T) {
+	testit("Long tables.text", false, t)
+}
+
+func TestTableContinuedCaption(t *testing.T) {
+	content, err := os.ReadFile("./testdata/Long tables.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts:  []RenderOption{WithTableContinuedCaption("(continued)")},
+	})
+	r.Extensions = parser.Tables
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, content); err != nil {
+		t.Fatal(err)
+	}
+	pages := r.Pdf.PageCount()
+	if pages < 2 {
+		t.Fatalf("expected the table to span several pages, got %d", pages)
+	}
+	// the caption and the header are repeated on every page but the first
+	if n := bytes.Count(buf.Bytes(), []byte("continued")); n != pages-1 {
+		t.Errorf("caption written %d times, expected %d", n, pages-1)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("(Description)")); n != pages {
+		t.Errorf("header written %d times, expected %d", n, pages)
+	}
+}
+
 func TestMarkdownDocumenationBasic(t *testing.T) {
 	testit("Markdown Documentation - Basics.text", false, t)
 }
@@ -168,3 +225,1064 @@ func TestLinksShortcut(t *testing.T) {
 func TestTidyness(t *testing.T) {
 	testit("Tidyness.text", false, t)
 }
+
+func TestProcessToWriter(t *testing.T) {
+	var pdf, trace bytes.Buffer
+	params := PdfRendererParams{
+		TracerOutput: &trace,
+		Theme:        LIGHT,
+	}
+	r := NewPdfRenderer(params)
+	err := r.ProcessTo(&pdf, []byte("# Heading\n\nSome *text*.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("output is not a PDF document")
+	}
+	if !strings.Contains(trace.String(), "[Text] Some ") {
+		t.Errorf("trace output missing text node:\n%s", trace.String())
+	}
+}
+
+func renderTables(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT})
+	r.Extensions = parser.Tables
+	r.Pdf.SetCatalogSort(true)
+	r.Pdf.SetCreationDate(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := r.ProcessTo(&buf, content); err != nil {
+		t.Error(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConcurrentTables(t *testing.T) {
+	content, err := os.ReadFile("./testdata/Tables.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := renderTables(t, content)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := renderTables(t, content); !bytes.Equal(got, want) {
+				t.Error("concurrent render differs from serial render")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentImages(t *testing.T) {
+	content := "![png](./image/fpdf.png)\n\n![jpeg](./image/bay.jpg)\n"
+	// converting SVG needs Chrome, which svg2png insists on finding
+	if slices.ContainsFunc(svg2png.DefaultChromPaths, func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}) {
+		svg := filepath.Join(t.TempDir(), "box.svg")
+		err := os.WriteFile(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20">`+
+			`<rect width="40" height="20" fill="red"/></svg>`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content += "\n![svg](" + svg + ")\n"
+	} else {
+		t.Log("Chrome not found, not rendering SVG")
+	}
+	render := func() []byte {
+		var buf bytes.Buffer
+		r := NewPdfRenderer(PdfRendererParams{
+			Theme: LIGHT,
+			Opts:  []RenderOption{WithStrictErrors(true), WithReproducible(time.Time{})},
+		})
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Error(err)
+		}
+		return buf.Bytes()
+	}
+	want := render()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := render(); !bytes.Equal(got, want) {
+				t.Error("concurrent render differs from serial render")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// admonition is the node made by the parser hook of TestNodeRenderer
+type admonition struct {
+	ast.Leaf
+}
+
+func TestNodeRenderer(t *testing.T) {
+	hook := func(data []byte) (ast.Node, []byte, int) {
+		if !bytes.HasPrefix(data, []byte("!!! ")) {
+			return nil, nil, 0
+		}
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			end = len(data)
+		}
+		return &admonition{ast.Leaf{Literal: data[4:end]}}, nil, end
+	}
+	headings := 0
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			WithStrictErrors(true),
+			WithParserHook(hook),
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				n, ok := node.(*admonition)
+				if !ok {
+					return ast.GoToNext, false
+				}
+				style := r.CurrentStyle()
+				style.Style = "b"
+				r.NewLine()
+				r.PushStyle(style)
+				r.WriteText("Note: " + string(n.Literal))
+				r.PopStyle()
+				return ast.GoToNext, true
+			}),
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				if _, ok := node.(*ast.Heading); !ok {
+					return ast.GoToNext, false
+				}
+				if entering {
+					headings++
+					r.NewLine()
+					r.PushStyle(r.H6)
+				} else {
+					r.PopStyle()
+					r.NewLine()
+				}
+				return ast.GoToNext, true
+			}),
+		},
+	})
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte("# Title\n\n!!! keep it short\n\nSome text.\n")); err != nil {
+		t.Fatal(err)
+	}
+	if headings != 1 {
+		t.Errorf("heading renderer called for %d headings", headings)
+	}
+	for _, s := range []string{"(Title)", "(Note: keep it short)", "(Some text.)"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("%v missing from the output", s)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	theme := path.Join(t.TempDir(), "theme.json")
+	if err := os.WriteFile(theme, []byte(`{"Normal": {"Font": "Arial",}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		theme   string
+		target  any
+	}{
+		{"theme", "text", theme, new(*ThemeError)},
+		{"image", "![alt](does-not-exist.png)", "", new(*ImageError)},
+		{"node", "x^2^", "", new(*UnsupportedNodeError)},
+		{"math", `$\frac{a}{\foo}$`, "", new(*MathError)},
+		{"diagram", "```mermaid\npie\n```\n", "", new(*DiagramError)},
+		{"code block", "```csv\n```\n", "", new(*CodeBlockError)},
+		{"front matter", "---\ntoc: maybe\n---\ntext", "", new(*FrontMatterError)},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{true, false} {
+			params := PdfRendererParams{
+				Theme:           LIGHT,
+				CustomThemeFile: tt.theme,
+				Opts:            []RenderOption{WithStrictErrors(strict)},
+			}
+			if tt.theme != "" {
+				params.Theme = CUSTOM
+			}
+			r := NewPdfRenderer(params)
+			r.Extensions = parser.SuperSubscript | parser.MathJax | parser.FencedCode
+			var buf bytes.Buffer
+			err := r.ProcessTo(&buf, []byte(tt.content))
+			if strict {
+				if !errors.As(err, tt.target) {
+					t.Errorf("%v: strict mode returned %v", tt.name, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%v: lenient mode returned %v", tt.name, err)
+			}
+			if len(r.Warnings()) != 1 || !errors.As(r.Warnings()[0], tt.target) {
+				t.Errorf("%v: lenient mode warnings %v", tt.name, r.Warnings())
+			}
+		}
+	}
+
+	r := NewPdfRenderer(PdfRendererParams{Theme: CUSTOM, CustomThemeFile: theme})
+	var themeErr *ThemeError
+	if !errors.As(r.Warnings()[0], &themeErr) || themeErr.Offset != 29 {
+		t.Errorf("unexpected theme error %#v", r.Warnings()[0])
+	}
+}
+
+func TestStrikethrough(t *testing.T) {
+	testit("Strikethrough.text", false, t)
+}
+
+func TestFootnotes(t *testing.T) {
+	testit("Footnotes.text", false, t)
+}
+
+func TestEndnotes(t *testing.T) {
+	content, err := os.ReadFile("./testdata/Footnotes.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts:  []RenderOption{WithFootnoteMode(FootnotesEndnotes), WithStrictErrors(true)},
+	})
+	r.Extensions = parser.CommonExtensions | parser.Footnotes
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pdfGlyph is a string written on a page, in the base font named
+type pdfGlyph struct {
+	font string
+	size float64
+	x, y float64
+	text string
+}
+
+// pdfGlyphs returns the strings written on the pages of an uncompressed
+// PDF, with their font and position
+func pdfGlyphs(pdf string) []pdfGlyph {
+	// font resource names, and the base fonts of the font objects
+	objects := map[string]string{}
+	for _, m := range regexp.MustCompile(`(\d+) 0 obj\n<</Type /Font\n/BaseFont /(\S+)`).FindAllStringSubmatch(pdf, -1) {
+		objects[m[1]] = m[2]
+	}
+	fonts := map[string]string{}
+	for _, m := range regexp.MustCompile(`/(F[0-9a-f]+) (\d+) 0 R`).FindAllStringSubmatch(pdf, -1) {
+		fonts[m[1]] = objects[m[2]]
+	}
+	var glyphs []pdfGlyph
+	font, size := "", 0.0
+	ops := regexp.MustCompile(`BT /(F[0-9a-f]+) ([\d.]+) Tf ET|BT ([\d.]+) ([\d.]+) Td \(((?:[^()\\]|\\.)*)\) ?Tj ET`)
+	for _, m := range ops.FindAllStringSubmatch(pdf, -1) {
+		if m[1] != "" {
+			font = fonts[m[1]]
+			size, _ = strconv.ParseFloat(m[2], 64)
+			continue
+		}
+		x, _ := strconv.ParseFloat(m[3], 64)
+		y, _ := strconv.ParseFloat(m[4], 64)
+		text := regexp.MustCompile(`\\(.)`).ReplaceAllString(m[5], "$1")
+		glyphs = append(glyphs, pdfGlyph{font: font, size: size, x: x, y: y, text: text})
+	}
+	return glyphs
+}
+
+func TestMath(t *testing.T) {
+	render := func(content string) string {
+		r := NewPdfRenderer(PdfRendererParams{
+			Theme: LIGHT,
+			Opts:  []RenderOption{WithMathNumbering(true), WithStrictErrors(true)},
+		})
+		r.Extensions = parser.CommonExtensions | parser.MathJax
+		r.Pdf.SetCompression(false)
+		var buf bytes.Buffer
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	find := func(glyphs []pdfGlyph, text string) pdfGlyph {
+		for _, g := range glyphs {
+			if g.text == text {
+				return g
+			}
+		}
+		t.Fatalf("%q not written", text)
+		return pdfGlyph{}
+	}
+
+	pdf := render("$\\frac{a}{b}$ $x^2$ $\\alpha \\leq y$\n\n| m |\n|---|\n| $\\beta^3$ |\n")
+	glyphs := pdfGlyphs(pdf)
+	// the numerator above the denominator, both smaller, with the
+	// fraction bar between them
+	a, b := find(glyphs, "a"), find(glyphs, "b")
+	if a.x != b.x || a.y <= b.y || a.size >= 12 || b.size != a.size {
+		t.Errorf("fraction: %+v over %+v", a, b)
+	}
+	if bar := regexp.MustCompile(`[\d.]+ ([\d.]+) m [\d.]+ [\d.]+ l S`).FindStringSubmatch(pdf); bar == nil {
+		t.Error("fraction bar missing")
+	} else if y, _ := strconv.ParseFloat(bar[1], 64); y <= b.y || y >= a.y {
+		t.Errorf("fraction bar at %v", y)
+	}
+	// the superscript raised, smaller and after its base
+	x, sup := find(glyphs, "x"), find(glyphs, "2")
+	if sup.y <= x.y || sup.size >= x.size || sup.x <= x.x {
+		t.Errorf("superscript: %+v on %+v", sup, x)
+	}
+	// symbols are taken from the Symbol font, letters from Times
+	if !slices.ContainsFunc(glyphs, func(g pdfGlyph) bool { return g.font == "Symbol" && g.text == "a" }) {
+		t.Error("alpha not mapped to the Symbol font")
+	}
+	if !slices.ContainsFunc(glyphs, func(g pdfGlyph) bool { return g.font == "Symbol" && g.text == "\xa3" }) {
+		t.Error("leq not mapped to the Symbol font")
+	}
+	if y := find(glyphs, "y"); y.font != "Times-Italic" {
+		t.Errorf("y written as %+v", y)
+	}
+	// math in a table cell is typeset too
+	if strings.Contains(pdf, "beta") {
+		t.Error("math in a cell written as TeX")
+	}
+	three := find(glyphs, "3")
+	i := slices.IndexFunc(glyphs, func(g pdfGlyph) bool { return g.text == "b" && g.font == "Symbol" })
+	if i < 0 {
+		t.Fatal("beta missing from the cell")
+	}
+	if beta := glyphs[i]; three.y <= beta.y || three.size >= beta.size {
+		t.Errorf("cell superscript: %+v on %+v", three, beta)
+	}
+
+	// display equations are numbered, unless tagged
+	content, err := os.ReadFile("./testdata/Math.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, g := range pdfGlyphs(render(string(content))) {
+		if strings.HasPrefix(g.text, "(") && strings.HasSuffix(g.text, ")") && g.x > 500 {
+			labels = append(labels, g.text)
+		}
+	}
+	want := []string{"(1)", "(2)", "(3)", "(4)", "(abs)", "(5)", "(6)"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("got equation labels %q, want %q", labels, want)
+	}
+}
+
+func TestTaskLists(t *testing.T) {
+	testit("Task lists.text", false, t)
+}
+
+func TestDefinitionLists(t *testing.T) {
+	testit("Definition lists.text", false, t)
+}
+
+func TestDiagrams(t *testing.T) {
+	testit("Diagrams.text", false, t)
+}
+
+func TestDiagramRenderer(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			WithStrictErrors(true),
+			WithDiagramRenderer("plantuml", func(r *PdfRenderer, source string) (Diagram, error) {
+				sources = append(sources, source)
+				return ImageDiagram(img.Bytes())
+			}),
+			WithDiagramRenderer("mermaid", nil),
+		},
+	})
+	r.Extensions = parser.FencedCode
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	content := "```plantuml\nA -> B\n```\n\n```mermaid\ngraph TD\nA --> B\n```\n"
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0] != "A -> B\n" {
+		t.Errorf("renderer called with %q", sources)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/Subtype /Image")) {
+		t.Error("diagram image missing")
+	}
+	// the mermaid renderer is disabled, so the block is printed as code
+	if !bytes.Contains(buf.Bytes(), []byte("(graph TD)")) {
+		t.Error("mermaid block not printed as code")
+	}
+}
+
+func TestCodeBlockHandlers(t *testing.T) {
+	testit("Code block handlers.text", false, t)
+}
+
+func TestCodeBlockHandler(t *testing.T) {
+	var infos []string
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			WithStrictErrors(true),
+			WithCodeBlockHandler("shout", func(r *PdfRenderer, info, source string) bool {
+				infos = append(infos, info)
+				if strings.Contains(info, "quiet") {
+					return false
+				}
+				r.NewLine()
+				r.WriteText(strings.ToUpper(source))
+				return true
+			}),
+			WithCodeBlockHandler("csv", nil),
+		},
+	})
+	r.Extensions = parser.FencedCode
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	content := "```shout\nhello\n```\n\n```shout quiet\nwhisper\n```\n\n```csv\na,b\n```\n"
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(infos, "|") != "shout|shout quiet" {
+		t.Errorf("handler called with %q", infos)
+	}
+	// the handler declined the quiet block and csv has no handler left
+	for _, s := range []string{"(HELLO)", "(whisper)", "(a,b)"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("%v missing from the output", s)
+		}
+	}
+}
+
+func TestCSVBlock(t *testing.T) {
+	r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT})
+	r.Extensions = parser.FencedCode | parser.Tables
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	content := "```csv\nname,qty\napple,3\n```\n\n| a |\n|---|\n| b |\n"
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"(name)", "(apple)", "(3)", "(b)"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("%v missing from the output", s)
+		}
+	}
+	// only the widths of the markdown table are kept
+	if len(r.ColumnWidths) != 1 || len(r.columnMinWidths) != 1 {
+		t.Errorf("got widths of %d and %d tables, want 1", len(r.ColumnWidths), len(r.columnMinWidths))
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	yamlDoc := "---\ntitle: Release notes\nauthor: Jane Doe\nkeywords: [release, notes]\ntheme: dark\npapersize: A5\ntoc: true\ndate: 2024-01-01\n---\n# Hello\n"
+	tomlDoc := "+++\ntitle = \"Release notes\"\nauthor = 'Jane Doe'\nkeywords = [\"release\", \"notes\"] # comment\ntheme = \"dark\"\npapersize = \"A5\"\ntoc = true\n[extra]\nfooter = true\n+++\n# Hello\n"
+	want := FrontMatter{
+		Title: "Release notes", Author: "Jane Doe", Keywords: []string{"release", "notes"},
+		Theme: "dark", PaperSize: "A5", TOC: true,
+	}
+	for _, doc := range []string{yamlDoc, tomlDoc} {
+		fm, body, err := ParseFrontMatter([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fm == nil || !reflect.DeepEqual(*fm, want) {
+			t.Errorf("got %+v, want %+v", fm, want)
+		}
+		if string(body) != "# Hello\n" {
+			t.Errorf("body %q", body)
+		}
+	}
+
+	// a leading rule without a closing one, or text between two rules
+	// (e.g. slides), is not front matter
+	for _, doc := range []string{
+		"# Hello\n", "---\n\nHello\n",
+		"---\n\n# Slide\n\n- one\n- two\n\n---\n\n# Next\n",
+		"---\n\nJust text\n\n---\n", "---\n---\ntext\n", "+++\n\nHello\n\n+++\n",
+	} {
+		fm, body, err := ParseFrontMatter([]byte(doc))
+		if fm != nil || err != nil || string(body) != doc {
+			t.Errorf("%q: got %+v, %q, %v", doc, fm, body, err)
+		}
+	}
+
+	params := PdfRendererParams{Theme: LIGHT, Papersz: "A4"}
+	if err := want.Apply(&params, ""); err != nil {
+		t.Fatal(err)
+	}
+	if params.Theme != DARK || params.Papersz != "A5" || params.Orientation != "" || len(params.Opts) != 1 {
+		t.Errorf("Apply set %+v", params)
+	}
+
+	// a theme file must be in the directory of the document
+	for theme, ok := range map[string]bool{
+		"themes/custom.json": true, "/etc/passwd": false, "../custom.json": false, "themes/../../custom.json": false,
+	} {
+		params := PdfRendererParams{Theme: LIGHT}
+		err := FrontMatter{Theme: theme}.Apply(&params, "docs")
+		var fmErr *FrontMatterError
+		switch {
+		case ok && (err != nil || params.Theme != CUSTOM || params.CustomThemeFile != filepath.Join("docs", theme)):
+			t.Errorf("%q: got %+v, %v", theme, params, err)
+		case !ok && (!errors.As(err, &fmErr) || params.Theme != LIGHT || params.CustomThemeFile != ""):
+			t.Errorf("%q: got %+v, %v, want it refused", theme, params, err)
+		}
+	}
+	if _, _, err := (FrontMatter{Theme: "custom.json"}).ResolveTheme(""); err == nil {
+		t.Error("theme file accepted without a document directory")
+	}
+
+	// Run strips the front matter
+	r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT, Opts: []RenderOption{WithStrictErrors(true)}})
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(yamlDoc)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("(Hello)")) || bytes.Contains(buf.Bytes(), []byte("Jane")) {
+		t.Error("front matter not stripped")
+	}
+
+	// and it is applied through Apply
+	fm, body, err := ParseFrontMatter([]byte(yamlDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params = PdfRendererParams{Theme: LIGHT}
+	if err := fm.Apply(&params, ""); err != nil {
+		t.Fatal(err)
+	}
+	r = NewPdfRenderer(params)
+	r.Pdf.SetCompression(false)
+	buf.Reset()
+	if err := r.ProcessTo(&buf, body); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"(Table of Contents)", `<rdf:li xml:lang="x-default">Release notes</rdf:li>`, "<rdf:li>Jane Doe</rdf:li>"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("%v missing from the output", s)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Metadata: Metadata{
+			Title:        "Release <notes>",
+			Author:       "Jane Doe",
+			Keywords:     []string{"release", "notes"},
+			Creator:      "md2pdf",
+			CreationDate: date,
+			Language:     "en-GB",
+		},
+	})
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte("text")); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"/CreationDate (D:20240102030405)",
+		"/ModDate (D:20240102030405)",
+		"/Lang (en-GB)",
+		`<rdf:li xml:lang="x-default">Release &lt;notes&gt;</rdf:li>`,
+		"<dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>",
+		"<pdf:Keywords>release, notes</pdf:Keywords>",
+		"<xmp:CreatorTool>md2pdf</xmp:CreatorTool>",
+		"<xmp:CreateDate>2024-01-02T03:04:05Z</xmp:CreateDate>",
+		"/Type /Metadata /Subtype /XML",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("%v missing from the output", s)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("<dc:description>")) {
+		t.Error("empty subject written")
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	content, err := os.ReadFile("testdata/Wide tables.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var outputs [2]bytes.Buffer
+	for i := range outputs {
+		r := NewPdfRenderer(PdfRendererParams{
+			Theme: LIGHT,
+			Opts:  []RenderOption{WithReproducible(date)},
+		})
+		r.Extensions = parser.Tables
+		if err := r.ProcessTo(&outputs[i], content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(outputs[0].Bytes(), outputs[1].Bytes()) {
+		t.Error("output differs between runs")
+	}
+	if !bytes.Contains(outputs[0].Bytes(), []byte("/CreationDate (D:20240102030405)")) {
+		t.Error("creation date not set")
+	}
+}
+
+func TestOutline(t *testing.T) {
+	content := "# One\n\n### One.a\n\n## One.b\n\n# Two\n\n## Skipped\n\n## Two.a\n\n### Two.a.i\n"
+	// outline returns the bookmarks, each with the title of its parent
+	outline := func(opts OutlineOptions) []string {
+		r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT, Opts: []RenderOption{WithOutline(opts)}})
+		r.Pdf.SetCompression(false)
+		var buf bytes.Buffer
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		re := regexp.MustCompile(`(\d+) 0 obj\n<</Title \((.*?)\)\n/Parent (\d+) 0 R`)
+		titles := map[string]string{}
+		var bookmarks []string
+		for _, m := range re.FindAllStringSubmatch(buf.String(), -1) {
+			titles[m[1]] = m[2]
+			bookmarks = append(bookmarks, m[2]+" in "+titles[m[3]])
+		}
+		return bookmarks
+	}
+
+	got := outline(OutlineOptions{MaxLevel: 6})
+	want := []string{"One in ", "One.a in One", "One.b in One", "Two in ", "Skipped in Two", "Two.a in Two", "Two.a.i in Two.a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = outline(OutlineOptions{MaxLevel: 2, Exclude: func(heading *ast.Heading) bool {
+		return ExtractTextFromNode(heading) == "Skipped"
+	}})
+	want = []string{"One in ", "One.b in One", "Two in ", "Two.a in Two"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Exclude gets the headings of the document, e.g. to look at their
+	// siblings: here, the headings right after another one
+	got = outline(OutlineOptions{MaxLevel: 6, Exclude: func(heading *ast.Heading) bool {
+		_, ok := ast.GetPrevNode(heading).(*ast.Heading)
+		return ok
+	}})
+	want = []string{"One in "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got = outline(OutlineOptions{}); len(got) != 0 {
+		t.Errorf("MaxLevel 0 gave %q", got)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	content := "# Guide\n\n[TOC]\n\n# Install\n\n---\n\n# Install\n\n---\n\n## Usage {#usage}\n\n### Options\n\n## More {#usage}\n"
+	var ids []string
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			IsHorizontalRuleNewPage(true), WithTableOfContents(TOCOptions{MaxLevel: 2}),
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				if h, ok := node.(*ast.Heading); ok && entering {
+					ids = append(ids, h.HeadingID)
+				}
+				return ast.GoToNext, false
+			}),
+		},
+	})
+	r.Pdf.SetCompression(false)
+	r.Extensions = parser.HeadingIDs | parser.AutoHeadingIDs
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	// the page numbers are added to the first page once the headings
+	// have been placed
+	re := regexp.MustCompile(`\(([^()]*)\) ?Tj`)
+	var texts []string
+	for _, m := range re.FindAllStringSubmatch(buf.String(), -1) {
+		if !strings.HasPrefix(m[1], "..") {
+			texts = append(texts, m[1])
+		}
+	}
+	want := []string{
+		"Guide", "Table of Contents", "Guide", "Install", "Install", "Usage", "More", "Install", "1", "1", "2", "3", "3",
+		"Install", "Usage", "Options", "More",
+	}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("got %q, want %q", texts, want)
+	}
+	if !strings.Contains(buf.String(), ".....") {
+		t.Error("no dot leaders")
+	}
+	// the heading IDs are those of the document
+	wantIDs := []string{"guide", "install", "install-1", "usage", "options", "usage"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("got heading IDs %q, want %q", ids, wantIDs)
+	}
+}
+
+func TestPageTemplates(t *testing.T) {
+	content := "[TOC]\n\n# Intro\n\ntext\n\n---\n\n## Usage\n\ntext\n\n---\n\ntext\n"
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			IsHorizontalRuleNewPage(true),
+			WithTableOfContents(TOCOptions{}),
+			WithReproducible(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+			WithHeader(PageTemplate{Left: "{section}", Right: "{date}", SkipTOCPages: true}),
+			WithFooter(PageTemplate{Left: "{author}", Center: "{title}", Right: "Page {page} of {pages}", SkipFirstPage: true}),
+		},
+		Metadata: Metadata{Title: "Guide", Author: "Jane Doe"},
+	})
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	pages := pageTexts(buf.String())
+	want := [][]string{
+		// the TOC, without header, and the start of the document
+		{"Table of Contents", "Intro", "Usage", "Intro", "text", "1", "2"},
+		{"Usage", "text", "Usage", "2024-01-02", "Jane Doe", "Guide", "Page 2 of 3"},
+		{"text", "Usage", "2024-01-02", "Jane Doe", "Guide", "Page 3 of 3"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %q, want %q", pages, want)
+	}
+}
+
+// pageTexts returns the text of each page of an uncompressed PDF, with the
+// TOC dot leaders left out
+func pageTexts(pdf string) [][]string {
+	var pages [][]string
+	for _, page := range strings.Split(pdf, "/Type /Page\n")[1:] {
+		var texts []string
+		for _, m := range regexp.MustCompile(`Td \(([^()]*)\) ?Tj`).FindAllStringSubmatch(page, -1) {
+			if !strings.HasPrefix(m[1], "..") {
+				texts = append(texts, m[1])
+			}
+		}
+		pages = append(pages, texts)
+	}
+	return pages
+}
+
+func TestPageNumbering(t *testing.T) {
+	content := "# Intro\n\nof {pages}\n\n---\n\n## Usage\n\ntext\n"
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			IsHorizontalRuleNewPage(true),
+			WithTableOfContents(TOCOptions{Title: "Contents, {pages} pages"}),
+			WithFooter(PageTemplate{Right: "{page}/{pages}"}),
+			// the TOC page is numbered apart
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				if h, ok := node.(*ast.Heading); ok && h.Level == 1 && entering {
+					r.RestartPageNumbering()
+				}
+				return ast.GoToNext, false
+			}),
+		},
+	})
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		// the title is written once the pages are counted
+		{"Intro", "Usage", "Contents, 2 pages", "1", "2", "1/1"},
+		// the text of the document is left as it is
+		{"Intro", "of {pages}", "1/2"},
+		{"Usage", "text", "2/2"},
+	}
+	if pages := pageTexts(buf.String()); !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %q, want %q", pages, want)
+	}
+}
+
+func TestDocumentMatter(t *testing.T) {
+	content := "{frontmatter}\n\n# Preface\n\ntext\n\n{mainmatter}\n\n# Usage\n\ntext\n\n---\n\n## Options\n\ntext\n\n" +
+		"{backmatter}\n\n# Glossary\n\ntext\n\n## Terms\n\n# Index\n"
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			IsHorizontalRuleNewPage(true),
+			WithTableOfContents(TOCOptions{}),
+			WithFooter(PageTemplate{Right: "{page} of {pages}"}),
+		},
+	})
+	r.Extensions |= parser.Mmark
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		// the TOC opens the front matter, numbered in Roman numerals
+		{"Table of Contents", "Preface", "Usage", "Options", "Appendix A. Glossary", "Terms", "Appendix B. Index",
+			"ii", "1", "2", "3", "3", "3", "i of ii"},
+		{"Preface", "text", "ii of ii"},
+		{"Usage", "text", "1 of 3"},
+		{"Options", "text", "2 of 3"},
+		{"Appendix A. ", "Glossary", "text", "Terms", "Appendix B. ", "Index", "3 of 3"},
+	}
+	if pages := pageTexts(buf.String()); !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %q, want %q", pages, want)
+	}
+	if !strings.Contains(buf.String(), "/Title (Appendix B. Index)") {
+		t.Error("appendix not lettered in the outline")
+	}
+}
+
+func TestHeadingNumbering(t *testing.T) {
+	content := "{frontmatter}\n\n# Preface\n\n{mainmatter}\n\n# Title\n\n## Scope\n\nSee (#terms) and [the options](#options).\n\n" +
+		"### Options\n\n## Terms\n\n#### Deep\n\n{backmatter}\n\n## Glossary\n\n### Words\n"
+	var headings []string
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			WithTableOfContents(TOCOptions{MaxLevel: 3}),
+			WithHeadingNumbering(NumberingOptions{StartLevel: 2, Styles: []NumberStyle{NumberDecimal, NumberLowerAlpha}}),
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				if h, ok := node.(*ast.Heading); ok && !entering {
+					headings = append(headings, ExtractTextFromNode(h))
+				}
+				return ast.GoToNext, false
+			}),
+		},
+	})
+	r.Extensions |= parser.Mmark | parser.AutoHeadingIDs
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Table of Contents", "Preface", "Title", "1 Scope", "1.a Options", "2 Terms", "Appendix A. Glossary", "A.a Words",
+			"ii", "1", "1", "1", "1", "2", "2"},
+		// the front matter isn't numbered
+		{"Preface"},
+		// the cross-reference shows the number of the heading
+		{"Title", "1 ", "Scope", "See ", "2", " and ", "the options", ".", "1.a ", "Options", "2 ", "Terms", "2.0.1 ", "Deep"},
+		{"Appendix A. ", "Glossary", "A.a ", "Words"},
+	}
+	if pages := pageTexts(buf.String()); !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %q, want %q", pages, want)
+	}
+	// both references go to the heading, not to a URI
+	if strings.Contains(buf.String(), "/URI") {
+		t.Error("cross-reference made an external link")
+	}
+	if !strings.Contains(buf.String(), "/Title (1.a Options)") {
+		t.Error("heading number missing from the outline")
+	}
+	// the numbers are kept out of the document
+	wantHeadings := []string{"Preface", "Title", "Scope", "Options", "Terms", "Deep", "Glossary", "Words"}
+	if !reflect.DeepEqual(headings, wantHeadings) {
+		t.Errorf("got headings %q, want %q", headings, wantHeadings)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	// the heading and the long paragraph after it land at every
+	// position at the bottom of the first page in turn
+	long := strings.Repeat("word ", 120)
+	render := func(n int, opts PaginationOptions) [][]string {
+		content := strings.Repeat("filler\n\n", n) + "# Heading\n\n" + long + "\n"
+		r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT, Opts: []RenderOption{WithPagination(opts)}})
+		r.Pdf.SetCompression(false)
+		var buf bytes.Buffer
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		return pageTexts(buf.String())
+	}
+	// lines of the long paragraph on each page, and whether the heading
+	// is alone at the bottom of a page
+	count := func(pages [][]string) (lines []int, alone bool) {
+		for _, texts := range pages {
+			n := 0
+			for _, s := range texts {
+				if strings.HasPrefix(s, "word") {
+					n++
+				}
+			}
+			if len(texts) > 0 && texts[len(texts)-1] == "Heading" {
+				alone = true
+			}
+			lines = append(lines, n)
+		}
+		return lines, alone
+	}
+
+	var split, short bool
+	for n := 20; n < 40; n++ {
+		lines, alone := count(render(n, PaginationOptions{}))
+		split = split || alone
+		short = short || len(lines) > 1 && lines[0] > 0 && (lines[0] < 2 || lines[1] < 2)
+
+		lines, alone = count(render(n, PaginationOptions{KeepWithNext: true, Orphans: 2, Widows: 2}))
+		if alone {
+			t.Errorf("%d fillers: heading alone at the bottom of the page", n)
+		}
+		if len(lines) > 1 && lines[0] > 0 && (lines[0] < 2 || lines[1] < 2) {
+			t.Errorf("%d fillers: paragraph split %v", n, lines)
+		}
+	}
+	if !split || !short {
+		t.Error("the fillers never made a bad page break without pagination control")
+	}
+}
+
+func TestPageBreakMargin(t *testing.T) {
+	r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT})
+	margin := func() float64 {
+		_, bm := r.Pdf.GetAutoPageBreak()
+		return bm
+	}
+	// a page break moved up for the widows of a paragraph stays moved up
+	// when a footnote referenced further on reserves room
+	r.pagination.shift = 20
+	r.Pdf.SetAutoPageBreak(true, r.pageBreakMargin())
+	r.addFootnote("1", r.Pdf.AddLink(), "A note.")
+	if r.fn.reserved == 0 || margin() != r.mbottom+r.fn.reserved+20 {
+		t.Errorf("margin %v, reserved %v", margin(), r.fn.reserved)
+	}
+	r.restorePageBreak()
+	if r.pagination.shift != 0 || margin() != r.mbottom+r.fn.reserved {
+		t.Errorf("margin %v after the paragraph, reserved %v", margin(), r.fn.reserved)
+	}
+}
+
+func TestTableFootnotes(t *testing.T) {
+	content := "| Name | Value |\n|------|-------|\n| speed[^1] | 42 |\n\n[^1]: Measured at noon.\n"
+	for _, mode := range []FootnoteMode{FootnotesPageBottom, FootnotesEndnotes} {
+		r := NewPdfRenderer(PdfRendererParams{
+			Theme: LIGHT,
+			Opts:  []RenderOption{WithFootnoteMode(mode), WithStrictErrors(true)},
+		})
+		r.Extensions = parser.CommonExtensions | parser.Footnotes
+		r.Pdf.SetCompression(false)
+		var buf bytes.Buffer
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		pdf := buf.String()
+		if !strings.Contains(pdf, "(Measured at noon.)") {
+			t.Errorf("mode %v: note missing", mode)
+		}
+		// the reference is raised, in a smaller font, and linked
+		m := regexp.MustCompile(`Td \(speed\)Tj[\s\S]*? 7\.20 Tf ET\n[^\n]*Td \(1\)Tj`).FindString(pdf)
+		if m == "" {
+			t.Errorf("mode %v: reference not written as a superscript", mode)
+		}
+		if !strings.Contains(pdf, "/Dest [") {
+			t.Errorf("mode %v: reference not linked", mode)
+		}
+	}
+}
+
+func TestTallTableRow(t *testing.T) {
+	content := "| Name | Notes |\n|------|-------|\n| long | " + strings.Repeat("word ", 1500) + "|\n| next | row |\n"
+	r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT, Opts: []RenderOption{WithStrictErrors(true)}})
+	r.Extensions = parser.CommonExtensions
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	pages := pageTexts(buf.String())
+	if len(pages) < 2 {
+		t.Fatalf("row not split: %d page", len(pages))
+	}
+	// the header is repeated above the rest of the row, and every word
+	// is written once, above the bottom margin
+	words := 0
+	for i, texts := range pages {
+		if len(texts) < 2 || texts[0] != "Name" || texts[1] != "Notes" {
+			t.Errorf("page %d starts with %q", i+1, texts[:min(len(texts), 2)])
+		}
+		for _, text := range texts {
+			words += strings.Count(text, "word")
+		}
+	}
+	if words != 1500 {
+		t.Errorf("got %d words, want 1500", words)
+	}
+	for _, m := range regexp.MustCompile(`BT [\d.]+ ([\d.]+) Td`).FindAllStringSubmatch(buf.String(), -1) {
+		if y, _ := strconv.ParseFloat(m[1], 64); y < r.mbottom {
+			t.Errorf("text written at %v, below the bottom margin", y)
+		}
+	}
+}
+
+func TestUnmeasuredTable(t *testing.T) {
+	r := NewPdfRenderer(PdfRendererParams{
+		Theme: LIGHT,
+		Opts: []RenderOption{
+			WithStrictErrors(true),
+			// as a renderer which doesn't measure its tables would leave it
+			WithNodeRenderer(func(r *PdfRenderer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				if _, ok := node.(*ast.Table); ok && entering {
+					delete(r.ColumnWidths, node)
+				}
+				return ast.GoToNext, false
+			}),
+		},
+	})
+	r.Extensions = parser.CommonExtensions
+	r.Pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := r.ProcessTo(&buf, []byte("| a | b |\n|---|---|\n| c | d |\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a", "b", "c", "d"}}
+	if pages := pageTexts(buf.String()); !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %q, want %q", pages, want)
+	}
+}
+
+func TestRepeatedFootnote(t *testing.T) {
+	rows := strings.Repeat("| row | 42 |\n", 80)
+	for name, content := range map[string]string{
+		"text":   "Speed[^1] and again[^1].\n\n[^1]: Measured at noon.\n",
+		"header": "| Name[^1] | Value |\n|------|-------|\n" + rows + "\n[^1]: Measured at noon.\n",
+	} {
+		r := NewPdfRenderer(PdfRendererParams{Theme: LIGHT, Opts: []RenderOption{WithStrictErrors(true)}})
+		r.Extensions = parser.CommonExtensions | parser.Footnotes
+		r.Pdf.SetCompression(false)
+		var buf bytes.Buffer
+		if err := r.ProcessTo(&buf, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		// the note is written once, however often it is referenced
+		if n := strings.Count(buf.String(), "(Measured at noon.)"); n != 1 {
+			t.Errorf("%v: note written %d times", name, n)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// renderMermaid is the built-in renderer of ```mermaid blocks; it
+// supports flowcharts (graph, flowchart) and sequence diagrams
+func renderMermaid(r *PdfRenderer, source string) (Diagram, error) {
+	var lines []string
+	for _, l := range strings.Split(source, "\n") {
+		if i := strings.Index(l, "%%"); i >= 0 {
+			l = l[:i]
+		}
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty diagram")
+	}
+	kind := strings.Fields(lines[0])[0]
+	switch kind {
+	case "graph", "flowchart":
+		return parseMermaidFlowchart(r, lines)
+	case "sequenceDiagram":
+		return parseMermaidSequence(r, lines[1:])
+	}
+	return nil, fmt.Errorf("unsupported mermaid diagram type %q", kind)
+}
+
+var (
+	// node id followed by an optional shape, e.g. A, A[text], A((text))
+	mermaidNodeRe = regexp.MustCompile(`^([\p{L}\p{N}_]+)\s*(\(\[|\[\[|\[\(|\(\(|\{\{|\[/|\[\\|\[|\(|\{|>)?`)
+	// links without text or with the text between pipes, e.g. -->, -.->, ==>, ---
+	mermaidLinkRe = regexp.MustCompile(`^<?(-{2,}|={2,}|-\.+-|-\.)([>ox]?)`)
+	// the end of links with the text in the middle, e.g. -- text -->
+	mermaidLinkEndRe = regexp.MustCompile(`(-{2,}|={2,}|\.+-)([>ox]?)`)
+)
+
+// mermaid shape openings, with their closing
+var mermaidShapes = map[string]struct {
+	close string
+	shape graphShape
+}{
+	"[": {"]", shapeRect}, "(": {")", shapeRound}, "([": {"])", shapeStadium},
+	"[[": {"]]", shapeRect}, "[(": {")]", shapeRound}, "((": {"))", shapeCircle},
+	"{": {"}", shapeDiamond}, "{{": {"}}", shapeHexagon}, ">": {"]", shapeRect},
+	"[/": {"/]", shapeRect}, `[\`: {`\]`, shapeRect},
+}
+
+// mermaidFlowchart parses one statement of a flowchart
+type mermaidFlowchart struct {
+	g *graph
+	s string
+}
+
+func parseMermaidFlowchart(r *PdfRenderer, lines []string) (Diagram, error) {
+	g := newGraph(r, shapeRect)
+	if f := strings.Fields(lines[0]); len(f) > 1 {
+		switch dir := strings.TrimSuffix(f[1], ";"); dir {
+		case "TD", "TB", "BT", "LR", "RL":
+			g.direction = strings.Replace(dir, "TD", "TB", 1)
+		default:
+			return nil, fmt.Errorf("unknown direction %q", dir)
+		}
+	}
+	for _, l := range lines[1:] {
+		for _, stmt := range strings.Split(l, ";") {
+			if stmt = strings.TrimSpace(stmt); stmt == "" {
+				continue
+			}
+			switch strings.Fields(stmt)[0] {
+			case "subgraph", "end", "direction", "classDef", "class", "style", "linkStyle", "click":
+				// styling and grouping are ignored
+				continue
+			}
+			p := &mermaidFlowchart{g: g, s: stmt}
+			if err := p.statement(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	g.layout(r.Pdf)
+	return g, nil
+}
+
+// statement parses a chain of nodes joined by links: A --> B & C -- text --> D
+func (p *mermaidFlowchart) statement() error {
+	from, err := p.nodes()
+	if err != nil {
+		return err
+	}
+	for p.skipSpace(); p.s != ""; p.skipSpace() {
+		label, dashed, thick, arrow, err := p.link()
+		if err != nil {
+			return err
+		}
+		to, err := p.nodes()
+		if err != nil {
+			return err
+		}
+		for _, a := range from {
+			for _, b := range to {
+				e := p.g.addEdge(a, b)
+				e.label, e.dashed, e.thick, e.arrow = label, dashed, thick, arrow
+			}
+		}
+		from = to
+	}
+	return nil
+}
+
+func (p *mermaidFlowchart) skipSpace() {
+	p.s = strings.TrimLeft(p.s, " \t")
+}
+
+// nodes parses node references joined by &
+func (p *mermaidFlowchart) nodes() ([]string, error) {
+	var ids []string
+	for {
+		p.skipSpace()
+		m := mermaidNodeRe.FindStringSubmatch(p.s)
+		if m == nil {
+			return nil, fmt.Errorf("expected a node at %q", p.s)
+		}
+		p.s = p.s[len(m[0]):]
+		n := p.g.node(m[1])
+		if m[2] != "" {
+			sh := mermaidShapes[m[2]]
+			end := strings.Index(p.s, sh.close)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated node %q", m[0]+p.s)
+			}
+			n.setLabel(strings.Trim(strings.TrimSpace(p.s[:end]), `"`))
+			n.shape = sh.shape
+			p.s = p.s[end+len(sh.close):]
+		}
+		ids = append(ids, m[1])
+		p.skipSpace()
+		if !strings.HasPrefix(p.s, "&") {
+			return ids, nil
+		}
+		p.s = p.s[1:]
+	}
+}
+
+// link parses a link and its optional text
+func (p *mermaidFlowchart) link() (label string, dashed, thick, arrow bool, err error) {
+	m := mermaidLinkRe.FindStringSubmatch(p.s)
+	if m == nil {
+		return "", false, false, false, fmt.Errorf("expected a link at %q", p.s)
+	}
+	p.s = p.s[len(m[0]):]
+	line, head := m[1], m[2]
+	if head == "" && (line == "--" || line == "==" || line == "-.") {
+		// text in the middle: -- text -->
+		end := mermaidLinkEndRe.FindStringSubmatchIndex(p.s)
+		if end == nil {
+			return "", false, false, false, fmt.Errorf("unterminated link at %q", p.s)
+		}
+		label = strings.TrimSpace(p.s[:end[0]])
+		head = p.s[end[4]:end[5]]
+		p.s = p.s[end[1]:]
+	} else if p.skipSpace(); strings.HasPrefix(p.s, "|") {
+		end := strings.Index(p.s[1:], "|")
+		if end < 0 {
+			return "", false, false, false, fmt.Errorf("unterminated link text at %q", p.s)
+		}
+		label = strings.TrimSpace(p.s[1 : end+1])
+		p.s = p.s[end+2:]
+	}
+	label = strings.Trim(label, `"`)
+	return label, strings.Contains(line, "."), line[0] == '=', head != "", nil
+}
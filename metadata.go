@@ -0,0 +1,106 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// Metadata is the document information stored in the PDF, both in its
+// information dictionary and as an XMP packet
+type Metadata struct {
+	Title, Author, Subject string
+	Keywords               []string
+	// Creator is the application which made the markdown, Producer the
+	// one which converted it to PDF
+	Creator, Producer string
+	// CreationDate defaults to the time the renderer is created and
+	// ModificationDate to CreationDate; set both for reproducible output
+	CreationDate, ModificationDate time.Time
+	// Language is the natural language of the text, e.g. "en-GB"
+	Language string
+}
+
+// SetMetadata stores m in the PDF document
+func (r *PdfRenderer) SetMetadata(m Metadata) {
+	if m.CreationDate.IsZero() {
+		m.CreationDate = time.Now()
+	}
+	if m.ModificationDate.IsZero() {
+		m.ModificationDate = m.CreationDate
+	}
+	r.metadata = m
+
+	r.Pdf.SetTitle(m.Title, true)
+	r.Pdf.SetAuthor(m.Author, true)
+	r.Pdf.SetSubject(m.Subject, true)
+	r.Pdf.SetKeywords(strings.Join(m.Keywords, ", "), true)
+	r.Pdf.SetCreator(m.Creator, true)
+	r.Pdf.SetProducer(m.Producer, true)
+	r.Pdf.SetCreationDate(m.CreationDate)
+	r.Pdf.SetModificationDate(m.ModificationDate)
+	r.Pdf.SetLang(m.Language)
+	r.Pdf.SetXmpMetadata(m.xmp())
+}
+
+// xmp returns the XMP packet describing the document
+func (m Metadata) xmp() []byte {
+	var b bytes.Buffer
+	text := func(s string) string {
+		var e bytes.Buffer
+		_ = xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	// element writes a simple property, list an rdf container of values
+	element := func(name, value string) {
+		if value != "" {
+			b.WriteString("   <" + name + ">" + text(value) + "</" + name + ">\n")
+		}
+	}
+	list := func(name, container string, values ...string) {
+		if len(values) == 0 || values[0] == "" {
+			return
+		}
+		b.WriteString("   <" + name + "><rdf:" + container + ">")
+		for _, v := range values {
+			if container == "Alt" {
+				b.WriteString(`<rdf:li xml:lang="x-default">` + text(v) + "</rdf:li>")
+			} else {
+				b.WriteString("<rdf:li>" + text(v) + "</rdf:li>")
+			}
+		}
+		b.WriteString("</rdf:" + container + "></" + name + ">\n")
+	}
+
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`  <rdf:Description rdf:about=""` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:pdf="http://ns.adobe.com/pdf/1.3/"` +
+		` xmlns:xmp="http://ns.adobe.com/xap/1.0/">` + "\n")
+	element("dc:format", "application/pdf")
+	list("dc:title", "Alt", m.Title)
+	list("dc:creator", "Seq", m.Author)
+	list("dc:description", "Alt", m.Subject)
+	list("dc:subject", "Bag", m.Keywords...)
+	list("dc:language", "Bag", m.Language)
+	element("pdf:Keywords", strings.Join(m.Keywords, ", "))
+	element("pdf:Producer", m.Producer)
+	element("xmp:CreatorTool", m.Creator)
+	element("xmp:CreateDate", m.CreationDate.Format(time.RFC3339))
+	element("xmp:ModifyDate", m.ModificationDate.Format(time.RFC3339))
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
@@ -20,10 +20,10 @@
 package mdtopdf
 
 import (
+	"crypto/sha1"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -45,14 +45,18 @@ func (r *PdfRenderer) processText(node *ast.Text) {
 	currentStyle := r.cs.peek().textStyle
 	r.setStyler(currentStyle)
 	s := string(node.Literal)
+	if node == r.taskText {
+		// the task list marker, drawn as a checkbox
+		s = strings.TrimLeft(s[3:], " ")
+		r.taskText = nil
+	}
 	if !r.NeedBlockquoteStyleUpdate {
 		s = strings.ReplaceAll(s, "\n", " ")
 	}
 	r.tracer("Text", s)
 
-	if incell {
-		r.cs.peek().cellInnerString += s
-		r.cs.peek().cellInnerStringStyle = &currentStyle
+	if r.tbl.incell {
+		r.addCellRun(tableRun{text: s, style: currentStyle, destination: r.cs.peek().destination})
 		return
 	}
 	switch node.Parent.(type) {
@@ -81,11 +85,74 @@ func (r *PdfRenderer) processText(node *ast.Text) {
 	}
 }
 
-// This is a stub implementation. For now, the MathAjax extension is disabled.
+// processMath typesets inline math ($...$, enabled with parser.MathJax)
+// on the current line, moving to the next one if it doesn't fit
 func (r *PdfRenderer) processMath(node *ast.Math) {
+	tex := string(node.Literal)
+	r.tracer("Math", tex)
 	currentStyle := r.cs.peek().textStyle
-	s := string(node.Literal)
-	r.write(currentStyle, s)
+	box, p := r.typesetMath(tex, currentStyle.Size, false)
+	if p.err != nil {
+		r.fail(&MathError{TeX: tex, Err: p.err})
+	}
+	if r.tbl.incell {
+		// drawn with the cell
+		r.addCellRun(tableRun{text: tex, style: currentStyle, math: box})
+		return
+	}
+	lh := currentStyle.Size + currentStyle.Spacing
+	x, y := r.Pdf.GetXY()
+	lm, _, _, _ := r.Pdf.GetMargins()
+	w, h := r.Pdf.GetPageSize()
+	if x+box.width > w-r.mright && x > lm {
+		r.cr()
+		x, y = r.Pdf.GetXY()
+	}
+	if _, bm := r.Pdf.GetAutoPageBreak(); y+lh > h-bm {
+		r.addPage()
+		x, y = r.Pdf.GetXY()
+	}
+	// the baseline of text written with Write
+	r.drawMath(box, x, y+lh/2+0.3*currentStyle.Size)
+	r.setStyler(currentStyle)
+	r.Pdf.SetXY(x+box.width, y)
+}
+
+// processMathBlock typesets display math ($$...$$), centered on its own
+// lines and numbered if MathNumbering is set
+func (r *PdfRenderer) processMathBlock(node *ast.MathBlock) {
+	tex := string(node.Literal)
+	r.tracer("MathBlock", tex)
+	box, p := r.typesetMath(tex, r.Math.Size, true)
+	if p.err != nil {
+		r.fail(&MathError{TeX: tex, Err: p.err})
+	}
+	label := ""
+	if p.tag != "" {
+		label = "(" + p.tag + ")"
+	} else if r.MathNumbering && !p.notag {
+		r.mathNumber++
+		label = fmt.Sprintf("(%d)", r.mathNumber)
+	}
+
+	r.cr()
+	pad := 0.5 * r.Math.Size
+	height := box.height + box.depth + 2*pad
+	_, y := r.Pdf.GetXY()
+	w, h := r.Pdf.GetPageSize()
+	if _, bm := r.Pdf.GetAutoPageBreak(); y+height > h-bm {
+		r.addPage()
+		_, y = r.Pdf.GetXY()
+	}
+	lm, _, _, _ := r.Pdf.GetMargins()
+	baseline := y + pad + box.height
+	r.drawMath(box, lm+(w-r.mright-lm-box.width)/2, baseline)
+	if label != "" {
+		r.Pdf.SetFont(r.Math.Font, "", r.Math.Size)
+		r.Pdf.Text(w-r.mright-r.Pdf.GetStringWidth(label), baseline, label)
+	}
+	r.setStyler(r.Normal)
+	r.Pdf.SetXY(lm, y+height)
 }
 
 func (r *PdfRenderer) outputUnhighlightedCodeBlock(codeBlock string) {
@@ -97,6 +164,11 @@ func (r *PdfRenderer) outputUnhighlightedCodeBlock(codeBlock string) {
 func (r *PdfRenderer) processCodeblock(node ast.CodeBlock) {
 	r.tracer("Codeblock", fmt.Sprintf("%v", ast.ToString(node.AsLeaf())))
 
+	handle := r.codeBlocks[codeBlockLanguage(string(node.Info))]
+	if handle != nil && handle(r, string(node.Info), string(node.Literal)) {
+		return
+	}
+
 	currentStyle := r.cs.peek().textStyle
 	r.setStyler(currentStyle)
 
@@ -208,23 +280,28 @@ func (r *PdfRenderer) processList(node ast.List, entering bool) {
 		kind = definition
 	}
 	r.setStyler(r.Normal)
+	// terms of a definition list are aligned with the surrounding text
+	indent := r.IndentValue
+	if kind == definition {
+		indent = 0
+	}
 	if entering {
 		r.tracer(fmt.Sprintf("%v List (entering)", kind),
 			fmt.Sprintf("%v", ast.ToString(node.AsContainer())))
-		r.Pdf.SetLeftMargin(r.cs.peek().leftMargin + r.IndentValue)
+		r.Pdf.SetLeftMargin(r.cs.peek().leftMargin + indent)
 		r.tracer("... List Left Margin",
-			fmt.Sprintf("set to %v", r.cs.peek().leftMargin+r.IndentValue))
+			fmt.Sprintf("set to %v", r.cs.peek().leftMargin+indent))
 		x := &containerState{
 			textStyle: r.Normal, itemNumber: 0,
 			listkind:   kind,
-			leftMargin: r.cs.peek().leftMargin + r.IndentValue}
+			leftMargin: r.cs.peek().leftMargin + indent}
 		r.cs.push(x)
 	} else {
 		r.tracer(fmt.Sprintf("%v List (leaving)", kind),
 			fmt.Sprintf("%v", ast.ToString(node.AsContainer())))
-		r.Pdf.SetLeftMargin(r.cs.peek().leftMargin - r.IndentValue)
+		r.Pdf.SetLeftMargin(r.cs.peek().leftMargin - indent)
 		r.tracer("... Reset List Left Margin",
-			fmt.Sprintf("re-set to %v", r.cs.peek().leftMargin-r.IndentValue))
+			fmt.Sprintf("re-set to %v", r.cs.peek().leftMargin-indent))
 		r.cs.pop()
 		if len(r.cs.stack) < 2 {
 			r.cr()
@@ -248,10 +325,31 @@ func (r *PdfRenderer) processItem(node ast.ListItem, entering bool) {
 			listkind:       r.cs.peek().listkind,
 			firstParagraph: true,
 			leftMargin:     r.cs.peek().leftMargin}
+		if node.RefLink != nil {
+			// an endnote; make it the target of its references
+			x.textStyle = r.Footnote
+			r.Pdf.SetLink(r.footnoteLink(string(node.RefLink)), -1, -1)
+		}
 		// add bullet or itemnumber; then set left margin for the
 		// text/paragraphs in the item
 		r.cs.push(x)
-		if r.cs.peek().listkind == unordered {
+		if x.listkind == definition {
+			// no bullet; the term in its own style, the definitions indented beneath it
+			textMargin := x.leftMargin + 4*r.em
+			if node.ListFlags&ast.ListTypeTerm != 0 {
+				x.textStyle = r.DefinitionTerm
+				textMargin = x.leftMargin
+			}
+			r.Pdf.SetLeftMargin(textMargin)
+			r.Pdf.SetX(textMargin)
+			return
+		}
+		if text, checked, ok := taskMarker(&node); ok {
+			r.tracer("... Task item", fmt.Sprintf("checked=%v", checked))
+			// the "[ ] " marker is left out when the text is written
+			r.taskText = text
+			r.drawCheckbox(x.textStyle, checked)
+		} else if r.cs.peek().listkind == unordered {
 			tr := r.Pdf.UnicodeTranslatorFromDescriptor("")
 			bulletChar := tr("•")
 			currFontSize, _ := r.Pdf.GetFontSize()
@@ -283,6 +381,66 @@ func (r *PdfRenderer) processItem(node ast.ListItem, entering bool) {
 	}
 }
 
+// taskMarker returns the text node of a GitHub style task list item
+// ("- [ ] todo", "- [x] done") and whether the task is checked. The
+// marker must start the plain text of the first paragraph of the item,
+// not e.g. a link or strong text.
+func taskMarker(item *ast.ListItem) (*ast.Text, bool, bool) {
+	children := item.GetChildren()
+	if len(children) == 0 {
+		return nil, false, false
+	}
+	para, ok := children[0].(*ast.Paragraph)
+	if !ok || len(para.GetChildren()) == 0 {
+		return nil, false, false
+	}
+	text, ok := para.GetChildren()[0].(*ast.Text)
+	if !ok {
+		return nil, false, false
+	}
+	l := text.Literal
+	// "[ ]" alone is an item with no text
+	if len(l) < 3 || l[0] != '[' || l[2] != ']' || len(l) > 3 && l[3] != ' ' {
+		return nil, false, false
+	}
+	switch l[1] {
+	case ' ':
+		return text, false, true
+	case 'x', 'X':
+		return text, true, true
+	}
+	return nil, false, false
+}
+
+// drawCheckbox draws a task list checkbox in place of the bullet, sized
+// to the item text style s
+func (r *PdfRenderer) drawCheckbox(s Styler, checked bool) {
+	lh := s.Size + s.Spacing
+	size := s.Size * 0.7
+	x, y := r.Pdf.GetXY()
+	bx := x + 4*r.em - size - r.em/2
+	by := y + (lh-size)/2
+	lw := r.Pdf.GetLineWidth()
+	dr, dg, db := r.Pdf.GetDrawColor()
+	fr, fg, fb := r.Pdf.GetFillColor()
+	c := r.Checkbox.TextColor
+	r.Pdf.SetDrawColor(c.Red, c.Green, c.Blue)
+	r.Pdf.SetFillColor(r.Checkbox.FillColor.Red, r.Checkbox.FillColor.Green, r.Checkbox.FillColor.Blue)
+	r.Pdf.SetLineWidth(0.8)
+	r.Pdf.Rect(bx, by, size, size, "FD")
+	if checked {
+		r.Pdf.SetLineWidth(1.5)
+		r.Pdf.SetLineCapStyle("round")
+		r.Pdf.Line(bx+size*0.2, by+size*0.5, bx+size*0.42, by+size*0.75)
+		r.Pdf.Line(bx+size*0.42, by+size*0.75, bx+size*0.82, by+size*0.22)
+		r.Pdf.SetLineCapStyle("butt")
+	}
+	r.Pdf.SetLineWidth(lw)
+	r.Pdf.SetDrawColor(dr, dg, db)
+	r.Pdf.SetFillColor(fr, fg, fb)
+	r.Pdf.SetX(x + 4*r.em)
+}
+
 func (r *PdfRenderer) processEmph(node ast.Node, entering bool) {
 	if entering {
 		r.tracer("Emph (entering)", "")
@@ -305,16 +463,33 @@ func (r *PdfRenderer) processStrong(node ast.Node, entering bool) {
 	}
 }
 
+// processDel relies on fpdf's strikeout font style ("S"), which draws
+// the line through each written chunk in the current text colour
+func (r *PdfRenderer) processDel(node ast.Node, entering bool) {
+	if entering {
+		r.cs.peek().textStyle.Style += "s"
+		r.tracer("Del (entering)", "")
+	} else {
+		r.tracer("Del (leaving)", "")
+		r.cs.peek().textStyle.Style = strings.ReplaceAll(
+			r.cs.peek().textStyle.Style, "s", "")
+	}
+}
+
 func (r *PdfRenderer) processLink(node ast.Link, entering bool) {
 	destination := string(node.Destination)
 	if entering {
-		if r.InputBaseURL != "" && !strings.HasPrefix(destination, "http") {
+		if r.InputBaseURL != "" && !strings.HasPrefix(destination, "http") && !strings.HasPrefix(destination, "#") {
 			destination = r.InputBaseURL + "/" + strings.Replace(destination, "./", "", 1)
 		}
 		x := &containerState{
 			textStyle: r.Link, listkind: notlist,
 			leftMargin:  r.cs.peek().leftMargin,
 			destination: destination}
+		// keep a surrounding strikethrough
+		if strings.Contains(r.cs.peek().textStyle.Style, "s") {
+			x.textStyle.Style += "s"
+		}
 		r.cs.push(x)
 		r.tracer("Link (entering)",
 			fmt.Sprintf("Destination[%v] Title[%v]",
@@ -326,10 +501,10 @@ func (r *PdfRenderer) processLink(node ast.Link, entering bool) {
 	}
 }
 
-func downloadFile(url, fileName string) error {
+func (r *PdfRenderer) downloadFile(url, fileName string) error {
 	client := http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			fmt.Println("Redirected to:", req.URL)
+			r.tracer("Image (redirected)", req.URL.String())
 			return nil
 		},
 	}
@@ -366,6 +541,44 @@ func downloadFile(url, fileName string) error {
 	return nil
 }
 
+// svgToPng converts the SVG file at destination to PNG (using headless Chrome)
+// and returns the path of the PNG file along with an image name derived from
+// the SVG contents. The temporary files are always uniquely named, so that
+// concurrent renderers never share them; the name is what keeps the output
+// stable between runs.
+func svgToPng(destination, tempDir string) (string, string, error) {
+	re := regexp.MustCompile(`<svg\s*.*\s*width="([0-9\.]+)"\sheight="([0-9\.]+)".*>`)
+	contents, err := os.ReadFile(destination)
+	if err != nil {
+		return "", "", err
+	}
+	matches := re.FindStringSubmatch(string(contents))
+	if matches == nil {
+		return "", "", errors.New("SVG has no width and height attributes")
+	}
+	os.MkdirAll(tempDir, 0755)
+	tf, err := os.CreateTemp(tempDir, "*.svg")
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := tf.Write(contents); err != nil {
+		tf.Close()
+		return "", "", err
+	}
+	if err := tf.Close(); err != nil {
+		return "", "", err
+	}
+	width, _ := strconv.ParseFloat(matches[1], 64)
+	height, _ := strconv.ParseFloat(matches[2], 64)
+	chrome := svg2png.NewChrome().SetHeight(int(height)).SetWith(int(width))
+	outputFileName := tf.Name() + ".png"
+	if err := chrome.Screenshoot(tf.Name(), outputFileName); err != nil {
+		return "", "", err
+	}
+	return outputFileName, fmt.Sprintf("%x.png", sha1.Sum(contents)), nil
+}
+
 func (r *PdfRenderer) processImage(node ast.Image, entering bool) {
 	// while this has entering and leaving states, it doesn't appear
 	// to be useful except for other markup languages to close the tag
@@ -382,61 +595,49 @@ func (r *PdfRenderer) processImage(node ast.Image, entering bool) {
 					source = r.InputBaseURL + "/" + destination
 				}
 			}
-			os.MkdirAll(tempDir, 755)
-			err := downloadFile(source, tempDir+"/"+filepath.Base(destination))
+			os.MkdirAll(tempDir, 0755)
+			err := r.downloadFile(source, tempDir+"/"+filepath.Base(destination))
 			if err != nil {
-				fmt.Println(err.Error())
-			} else {
-				destination = tempDir + "/" + filepath.Base(destination)
-				fmt.Println("Downloaded image to: " + destination)
+				r.fail(&ImageError{Destination: destination, Err: err})
+				return
 			}
+			destination = tempDir + "/" + filepath.Base(destination)
+			r.tracer("Image (downloaded)", destination)
 		}
 		mtype, err := mimetype.DetectFile(destination)
+		if err != nil {
+			r.fail(&ImageError{Destination: destination, Err: err})
+			return
+		}
 		if mtype.Is("image/svg+xml") {
-			re := regexp.MustCompile(`<svg\s*.*\s*width="([0-9\.]+)"\sheight="([0-9\.]+)".*>`)
-			contents, _ := os.ReadFile(destination)
-			matches := re.FindStringSubmatch(string(contents))
-			tf, err := os.CreateTemp(tempDir, "*.svg")
+			png, name, err := svgToPng(destination, tempDir)
 			if err != nil {
-				log.Println(err)
-				return
-			}
-
-			if _, err := tf.Write(contents); err != nil {
-				tf.Close()
-				log.Println(err)
+				r.fail(&ImageError{Destination: string(node.Destination), Err: err})
 				return
 			}
-			if err := tf.Close(); err != nil {
-				log.Println(err)
-				return
-			}
-			os.Rename(destination, tf.Name())
-			destination = tf.Name()
-			width, _ := strconv.ParseFloat(matches[1], 64)
-			height, _ := strconv.ParseFloat(matches[2], 64)
-			chrome := svg2png.NewChrome().SetHeight(int(height)).SetWith(int(width))
-			outputFileName := destination + ".png"
-			if err := chrome.Screenshoot(destination, outputFileName); err != nil {
-				log.Println(err)
+			f, err := os.Open(png)
+			if err != nil {
+				r.fail(&ImageError{Destination: string(node.Destination), Err: err})
 				return
 			}
-			destination = outputFileName
+			// register the PNG under its content name; the image is then
+			// looked up by that name rather than by the temporary file
+			r.Pdf.RegisterImageOptionsReader(name,
+				fpdf.ImageOptions{ImageType: "png", ReadDpi: true}, f)
+			f.Close()
+			destination = name
 		}
 		r.tracer("Image (entering)",
 			fmt.Sprintf("Destination[%v] Title[%v]",
 				destination,
 				string(node.Title)))
-		// following changes suggested by @sirnewton01, issue #6
-		// does file exist?
-		var imgPath = destination
-		_, err = os.Stat(imgPath)
-		if err == nil {
-			r.Pdf.ImageOptions(destination,
-				-1, 0, 0, 0, true,
-				fpdf.ImageOptions{ImageType: "", ReadDpi: true}, 0, "")
-		} else {
-			r.tracer("Image (file error)", err.Error())
+		r.Pdf.ImageOptions(destination,
+			-1, 0, 0, 0, true,
+			fpdf.ImageOptions{ImageType: "", ReadDpi: true}, 0, "")
+		if err := r.Pdf.Error(); err != nil {
+			// don't leave the whole document in an error state
+			r.Pdf.ClearError()
+			r.fail(&ImageError{Destination: destination, Err: err})
 		}
 	} else {
 		r.tracer("Image (leaving)", "")
@@ -445,6 +646,10 @@ func (r *PdfRenderer) processImage(node ast.Image, entering bool) {
 
 func (r *PdfRenderer) processCode(node ast.Node) {
 	r.tracer("processCode", fmt.Sprintf("%s", string(node.AsLeaf().Literal)))
+	if r.tbl.incell {
+		r.addCellRun(tableRun{text: string(node.AsLeaf().Literal), style: r.Backtick, fill: true})
+		return
+	}
 	if r.NeedCodeStyleUpdate {
 		r.tracer("Code (entering)", "")
 		r.setStyler(r.Code)
@@ -478,6 +683,7 @@ func (r *PdfRenderer) processParagraph(node *ast.Paragraph, entering bool) {
 			}
 			return
 		}
+		r.keepLines(node)
 		r.cr()
 	} else {
 		r.tracer("Paragraph (leaving)", "")
@@ -496,6 +702,7 @@ func (r *PdfRenderer) processParagraph(node *ast.Paragraph, entering bool) {
 			}
 			return
 		}
+		r.restorePageBreak()
 		r.cr()
 	}
 }
@@ -518,9 +725,14 @@ func (r *PdfRenderer) processBlockQuote(node ast.Node, entering bool) {
 	}
 }
 
-func (r *PdfRenderer) processHeading(node ast.Heading, entering bool) {
+func (r *PdfRenderer) processHeading(node *ast.Heading, entering bool) {
 	if entering {
+		r.keepWithNext(node)
 		r.cr()
+		r.bookmark(node)
+		r.tocHeading(node)
+		r.anchorHeading(node)
+		r.sectionHeading(node)
 		switch node.Level {
 		case 1:
 			r.tracer("Heading (1, entering)", fmt.Sprintf("%v", ast.ToString(node.AsContainer())))
@@ -559,6 +771,12 @@ func (r *PdfRenderer) processHeading(node ast.Heading, entering bool) {
 				leftMargin: r.cs.peek().leftMargin}
 			r.cs.push(x)
 		}
+		// the number or appendix label, kept apart from the heading text
+		if label := r.headingLabels[node]; label != "" {
+			s := r.cs.peek().textStyle
+			r.setStyler(s)
+			r.write(s, label)
+		}
 	} else {
 		r.tracer("Heading (leaving)", "")
 		r.cr()
@@ -569,7 +787,7 @@ func (r *PdfRenderer) processHeading(node ast.Heading, entering bool) {
 func (r *PdfRenderer) processHorizontalRule(node ast.Node) {
 	r.tracer("HorizontalRule", "")
 	if r.HorizontalRuleNewPage {
-		r.Pdf.AddPage()
+		r.addPage()
 	} else {
 		// do a newline
 		r.cr()
@@ -610,14 +828,18 @@ func (r *PdfRenderer) processTable(node ast.Node, entering bool) {
 			leftMargin: r.cs.peek().leftMargin}
 		r.cr()
 		r.cs.push(x)
-		fill = false
-		cellwidths = r.ColumnWidths[node]
+		r.tbl.fill = false
+		r.tbl.header = nil
+		r.tbl.headerPending = false
+		r.tbl.left, _, _, _ = r.Pdf.GetMargins()
+		w, _ := r.Pdf.GetPageSize()
+		r.tbl.cellwidths = fitColumnWidths(r.ColumnWidths[node], r.columnMinWidths[node],
+			w-r.tbl.left-r.mright)
+		r.Pdf.SetX(r.tbl.left)
 	} else {
-		wSum := 0.0
-		for _, w := range cellwidths {
-			wSum += w
-		}
-		r.Pdf.CellFormat(wSum, 0, "", "T", 0, "", false, 0, "")
+		// a table without a body
+		r.drawPendingHeader()
+		r.Pdf.CellFormat(r.tableWidth(), 0, "", "T", 0, "", false, 0, "")
 
 		r.cs.pop()
 		r.tracer("Table (leaving)", "")
@@ -648,7 +870,6 @@ func (r *PdfRenderer) processTableBody(node ast.Node, entering bool) {
 	} else {
 		r.cs.pop()
 		r.tracer("TableBody (leaving)", "")
-		r.Pdf.Ln(-1)
 	}
 }
 
@@ -661,15 +882,15 @@ func (r *PdfRenderer) processTableRow(node ast.Node, entering bool) {
 		if r.cs.peek().isHeader {
 			x.textStyle = r.THeader
 		}
-		r.Pdf.Ln(-1)
 
-		// initialize cell widths slice; only one table at a time!
-		curdatacell = 0
+		// the cells are drawn once the whole row is known
+		r.tbl.curdatacell = 0
+		r.tbl.row = nil
 		r.cs.push(x)
 	} else {
 		r.cs.pop()
+		r.placeTableRow(r.tbl.row)
 		r.tracer("TableRow (leaving)", "")
-		fill = !fill
 	}
 }
 
@@ -690,28 +911,15 @@ func (r *PdfRenderer) processTableCell(node ast.TableCell, entering bool) {
 			x.isHeader = false
 		}
 		r.cs.push(x)
-		incell = true
+		r.tbl.incell = true
+		r.tbl.runs = nil
 	} else {
-		incell = false
+		r.tbl.incell = false
 		cs := r.cs.pop()
-		currentStyle := cs.textStyle
-		if cs.cellInnerStringStyle != nil {
-			currentStyle = *cs.cellInnerStringStyle
-		}
-		s := cs.cellInnerString
-		w := cellwidths[curdatacell]
-		if cs.isHeader {
-			h, _ := r.Pdf.GetFontSize()
-			h += currentStyle.Spacing
-			r.tracer("... table header cell",
-				fmt.Sprintf("Width=%v, height=%v", w, h))
-
-			r.Pdf.CellFormat(w, h, s, "1", 0, "C", true, 0, "")
-		} else {
-			h := currentStyle.Size + currentStyle.Spacing
-			r.Pdf.CellFormat(w, h, s, "LR", 0, "", fill, 0, "")
-		}
+		cell := tableCell{style: cs.textStyle, header: cs.isHeader, align: cellAlign(node)}
+		cell.runs = r.tbl.runs
+		r.tbl.row = append(r.tbl.row, cell)
 		r.tracer("TableCell (leaving)", "")
-		curdatacell++
+		r.tbl.curdatacell++
 	}
 }
@@ -0,0 +1,201 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// NumberStyle is the kind of numerals a heading level is numbered in
+type NumberStyle int
+
+// Heading number styles
+const (
+	NumberDecimal    NumberStyle = iota // 1, 2, 3
+	NumberUpperAlpha                    // A, B, C
+	NumberLowerAlpha                    // a, b, c
+	NumberUpperRoman                    // I, II, III
+	NumberLowerRoman                    // i, ii, iii
+)
+
+// format returns n in the numerals of the style
+func (s NumberStyle) format(n int) string {
+	switch s {
+	case NumberUpperAlpha:
+		return letters(n)
+	case NumberLowerAlpha:
+		return strings.ToLower(letters(n))
+	case NumberUpperRoman:
+		return strings.ToUpper(roman(n))
+	case NumberLowerRoman:
+		return roman(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// NumberingOptions configures the numbering of the headings, e.g. "1.2.3"
+type NumberingOptions struct {
+	// StartLevel is the first heading level numbered, e.g. 2 to leave a
+	// H1 title out; 0 disables the numbering
+	StartLevel int
+	// MaxLevel is the deepest heading level numbered, 6 if 0
+	MaxLevel int
+	// Styles are the numerals of each level from StartLevel on; the
+	// levels past the end of Styles are numbered in decimal. A level
+	// skipped, e.g. by a H3 right under a H1, is numbered 0, as in "1.0.1"
+	Styles []NumberStyle
+}
+
+// style returns the numerals of a heading level
+func (o NumberingOptions) style(level int) NumberStyle {
+	if i := level - o.StartLevel; i >= 0 && i < len(o.Styles) {
+		return o.Styles[i]
+	}
+	return NumberDecimal
+}
+
+// headingRef is where links to a heading, by its ID, go
+type headingRef struct {
+	link int
+	// number is the heading number, if any, and title its text
+	number, title string
+	placed        bool
+}
+
+// prepareHeadings numbers the headings of doc, keeping the labels apart
+// from the document for the headings, the table of contents, the outline
+// and the page headers to show (see headingText), and makes the headings
+// with an ID link targets. Headings of the front matter aren't numbered;
+// in the back matter, the numbers start again under the appendix letters.
+func (r *PdfRenderer) prepareHeadings(doc ast.Node, appendices map[*ast.Heading]string) {
+	r.refs = make(map[string]*headingRef)
+	r.headingLabels = make(map[*ast.Heading]string)
+	for h, letter := range appendices {
+		r.headingLabels[h] = "Appendix " + letter + ". "
+	}
+	opts := r.Numbering
+	maxLevel := opts.MaxLevel
+	if maxLevel == 0 {
+		maxLevel = 6
+	}
+	var counts [7]int
+	var labels [7]string
+	matter := ast.DocumentMatterNone
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.DocumentMatter:
+			matter = node.Matter
+			counts, labels = [7]int{}, [7]string{}
+		case *ast.Heading:
+			if node.IsTitleblock {
+				return ast.SkipChildren
+			}
+			level := node.Level
+			for l := level + 1; l < len(counts); l++ {
+				counts[l], labels[l] = 0, ""
+			}
+			counts[level]++
+			letter, isAppendix := appendices[node]
+			if isAppendix {
+				labels[level] = letter
+			} else {
+				labels[level] = opts.style(level).format(counts[level])
+			}
+
+			number := ""
+			if opts.StartLevel > 0 && level >= opts.StartLevel && level <= maxLevel && matter != ast.DocumentMatterFront {
+				parts := make([]string, 0, level-opts.StartLevel+1)
+				for l := opts.StartLevel; l <= level; l++ {
+					if labels[l] == "" {
+						// a level skipped, e.g. a H3 right under a H1
+						labels[l] = "0"
+					}
+					parts = append(parts, labels[l])
+				}
+				number = strings.Join(parts, ".")
+				// appendices are labelled already
+				if !isAppendix {
+					r.headingLabels[node] = number + " "
+				}
+			}
+			if node.HeadingID != "" {
+				if _, taken := r.refs[node.HeadingID]; !taken {
+					r.refs[node.HeadingID] = &headingRef{link: r.Pdf.AddLink(), number: number, title: r.headingText(node)}
+				}
+			}
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+}
+
+// headingText returns the text of heading, after its number or appendix
+// label if any
+func (r *PdfRenderer) headingText(heading *ast.Heading) string {
+	return r.headingLabels[heading] + ExtractTextFromNode(heading)
+}
+
+// headingRef returns the heading a "#id" url refers to, or nil
+func (r *PdfRenderer) headingRef(url string) *headingRef {
+	if !strings.HasPrefix(url, "#") {
+		return nil
+	}
+	return r.refs[url[1:]]
+}
+
+// anchorHeading makes heading the target of the links to its ID
+func (r *PdfRenderer) anchorHeading(heading *ast.Heading) {
+	if ref, ok := r.refs[heading.HeadingID]; ok && !ref.placed {
+		r.Pdf.SetLink(ref.link, -1, -1)
+		ref.placed = true
+	}
+}
+
+// processCrossReference writes a reference to a heading (see parser.Mmark)
+// as its number, its title if it isn't numbered, or the text given after
+// the ID, e.g. "(#install, the install section)"
+func (r *PdfRenderer) processCrossReference(node *ast.CrossReference) {
+	id := string(node.Destination)
+	r.tracer("CrossReference", id)
+	ref := r.refs[id]
+	display := string(node.Suffix)
+	if ref == nil {
+		// no such heading: write the ID as it is
+		if display == "" {
+			display = id
+		}
+		if r.tbl.incell {
+			r.addCellRun(tableRun{text: display, style: r.cs.peek().textStyle})
+			return
+		}
+		r.write(r.cs.peek().textStyle, display)
+		return
+	}
+	switch {
+	case display != "":
+	case ref.number != "":
+		display = ref.number
+	default:
+		display = ref.title
+	}
+	s := r.Link
+	if r.tbl.incell {
+		r.addCellRun(tableRun{text: display, style: s, destination: "#" + id})
+		return
+	}
+	r.setStyler(s)
+	r.writeLink(s, display, "#"+id)
+}
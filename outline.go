@@ -0,0 +1,49 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// OutlineOptions configures the PDF outline (the bookmarks shown in the
+// sidebar of PDF viewers) made from the headings
+type OutlineOptions struct {
+	// MaxLevel is the deepest heading level bookmarked, e.g. 2 for
+	// H1 and H2 only; 0 disables the outline
+	MaxLevel int
+	// Exclude, if set, leaves out the headings for which it returns true;
+	// it is given the heading nodes of the document
+	Exclude func(heading *ast.Heading) bool
+}
+
+// outlineState tracks the headings the next bookmark nests under
+type outlineState struct {
+	// levels of the enclosing headings
+	open []int
+}
+
+// bookmark adds heading to the outline, at the current position
+func (r *PdfRenderer) bookmark(heading *ast.Heading) {
+	if heading.Level > r.Outline.MaxLevel {
+		return
+	}
+	if r.Outline.Exclude != nil && r.Outline.Exclude(heading) {
+		return
+	}
+	// nest under the enclosing headings, so that skipped levels
+	// (an H3 right under an H1) don't leave gaps in the outline
+	open := r.outline.open
+	for len(open) > 0 && open[len(open)-1] >= heading.Level {
+		open = open[:len(open)-1]
+	}
+	r.Pdf.Bookmark(r.headingText(heading), len(open), -1)
+	r.outline.open = append(open, heading.Level)
+}
@@ -0,0 +1,187 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// PageTemplate is the text written in the top (header) or bottom (footer)
+// margin of the pages, in three slots aligned left, centered and right.
+// The slots may hold the placeholders:
+//
+//	{page}     the page number, e.g. "iii" in a front matter
+//	{pages}    the number of pages, counted from the last restart of the
+//	           page numbering (see RestartPageNumbering)
+//	{title}    the title of the document (see Metadata)
+//	{author}   its author
+//	{date}     its creation date, as 2006-01-02
+//	{section}  the first H1 or H2 heading of the page, or the last one before it
+type PageTemplate struct {
+	Left, Center, Right string
+	// SkipFirstPage leaves out the first page, e.g. a title page
+	SkipFirstPage bool
+	// SkipTOCPages leaves out the pages of the table of contents
+	SkipTOCPages bool
+}
+
+// pageState records what page headers and footers refer to
+type pageState struct {
+	header, footer *PageTemplate
+	// H1 and H2 headings, with the page they are on
+	sections []pageSection
+	// pages of the table of contents
+	tocPages map[int]bool
+	// where the page numbering restarts; the pages before the first
+	// restart are numbered from 1 in Arabic numerals
+	restarts []pageNumbering
+}
+
+// pageNumbering numbers the pages from page on
+type pageNumbering struct {
+	page  int
+	roman bool
+}
+
+// format returns n as a page number of the numbering
+func (p pageNumbering) format(n int) string {
+	if p.roman {
+		return roman(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// PagesAlias is replaced, in the title of the table of contents, with the
+// number of pages of the last page numbering, i.e. the main matter when
+// the front matter is numbered apart; the text of the document is left
+// as it is
+const PagesAlias = "{pages}"
+
+type pageSection struct {
+	page  int
+	title string
+}
+
+// sectionHeading records heading as the current section
+func (r *PdfRenderer) sectionHeading(heading *ast.Heading) {
+	if heading.Level <= 2 {
+		r.pages.sections = append(r.pages.sections, pageSection{page: r.Pdf.PageNo(), title: r.headingText(heading)})
+	}
+}
+
+// section returns the section shown on page
+func (r *PdfRenderer) section(page int) string {
+	title := ""
+	for _, s := range r.pages.sections {
+		if s.page > page {
+			break
+		}
+		title = s.title
+		if s.page == page {
+			break
+		}
+	}
+	return title
+}
+
+// RestartPageNumbering numbers the current page 1, and the pages after it
+// from there on, in the same numerals; the pages before it are counted
+// apart, e.g. for "Page 2 of 3" footers in the front matter
+func (r *PdfRenderer) RestartPageNumbering() {
+	numbering, _, _ := r.pageNumber(r.Pdf.PageNo())
+	r.restartPageNumbering(numbering.roman)
+}
+
+func (r *PdfRenderer) restartPageNumbering(roman bool) {
+	page := r.Pdf.PageNo()
+	if n := len(r.pages.restarts); n > 0 && r.pages.restarts[n-1].page == page {
+		r.pages.restarts[n-1].roman = roman
+		return
+	}
+	r.pages.restarts = append(r.pages.restarts, pageNumbering{page: page, roman: roman})
+}
+
+// pageNumber returns the numbering of page, the number of page in it and
+// its number of pages
+func (r *PdfRenderer) pageNumber(page int) (numbering pageNumbering, n, count int) {
+	numbering = pageNumbering{page: 1}
+	next := r.Pdf.PageCount() + 1
+	for _, p := range r.pages.restarts {
+		if p.page > page {
+			next = p.page
+			break
+		}
+		numbering = p
+	}
+	return numbering, page - numbering.page + 1, next - numbering.page
+}
+
+// pageLabel returns the number of page, as printed in the headers,
+// footers and table of contents
+func (r *PdfRenderer) pageLabel(page int) string {
+	numbering, n, _ := r.pageNumber(page)
+	return numbering.format(n)
+}
+
+// expand replaces the placeholders of a template slot
+func (r *PdfRenderer) expand(slot string, page int) string {
+	if !strings.Contains(slot, "{") {
+		return slot
+	}
+	m := r.metadata
+	numbering, n, count := r.pageNumber(page)
+	return strings.NewReplacer(
+		"{page}", numbering.format(n),
+		"{pages}", numbering.format(count),
+		"{title}", m.Title,
+		"{author}", m.Author,
+		"{date}", m.CreationDate.Format("2006-01-02"),
+		"{section}", r.section(page),
+	).Replace(slot)
+}
+
+// finishPages writes the headers and footers, once the number of pages
+// and their sections are known
+func (r *PdfRenderer) finishPages() {
+	if r.pages.header == nil && r.pages.footer == nil {
+		return
+	}
+	last := r.Pdf.PageNo()
+	_, bm := r.Pdf.GetAutoPageBreak()
+	for page := 1; page <= r.Pdf.PageCount(); page++ {
+		r.Pdf.SetPage(page)
+		_, ph := r.Pdf.GetPageSize()
+		// baselines in the middle of the top and bottom margins
+		r.drawPageTemplate(r.pages.header, page, r.mtop/2+0.3*r.HeaderFooter.Size)
+		r.drawPageTemplate(r.pages.footer, page, ph-bm/2+0.3*r.HeaderFooter.Size)
+	}
+	r.Pdf.SetPage(last)
+}
+
+func (r *PdfRenderer) drawPageTemplate(t *PageTemplate, page int, baseline float64) {
+	if t == nil || t.SkipFirstPage && page == 1 || t.SkipTOCPages && r.pages.tocPages[page] {
+		return
+	}
+	r.setStyler(r.HeaderFooter)
+	lm := r.mleft
+	pw, _ := r.Pdf.GetPageSize()
+	if s := r.expand(t.Left, page); s != "" {
+		r.Pdf.Text(lm, baseline, s)
+	}
+	if s := r.expand(t.Center, page); s != "" {
+		r.Pdf.Text(lm+(pw-r.mright-lm-r.Pdf.GetStringWidth(s))/2, baseline, s)
+	}
+	if s := r.expand(t.Right, page); s != "" {
+		r.Pdf.Text(pw-r.mright-r.Pdf.GetStringWidth(s), baseline, s)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// PaginationOptions controls where the pages break
+type PaginationOptions struct {
+	// KeepWithNext moves a heading to the next page when it would be
+	// left at the bottom of a page, apart from the block following it
+	KeepWithNext bool
+	// Orphans is the least number of lines of a paragraph left at the
+	// bottom of a page, and Widows the least carried over to the top of
+	// the next one; 0 or 1 lets the page break after any line
+	Orphans, Widows int
+}
+
+// paginationState tracks a page break moved up for a paragraph
+type paginationState struct {
+	// lines the page break trigger was raised by
+	shift float64
+}
+
+// spaceLeft returns the room left on the page below the cursor
+func (r *PdfRenderer) spaceLeft() float64 {
+	_, ph := r.Pdf.GetPageSize()
+	_, bm := r.Pdf.GetAutoPageBreak()
+	return ph - bm - r.Pdf.GetY()
+}
+
+// atPageTop tells whether nothing was written on the page yet
+func (r *PdfRenderer) atPageTop() bool {
+	return r.Pdf.GetY() <= r.mtop+0.01
+}
+
+// textLines returns the number of lines text takes in style s
+func (r *PdfRenderer) textLines(text string, s Styler) int {
+	r.setStyler(s)
+	lm, _, _, _ := r.Pdf.GetMargins()
+	pw, _ := r.Pdf.GetPageSize()
+	return max(len(r.Pdf.SplitText(text, pw-r.mright-lm)), 1)
+}
+
+// keepWithNext starts a new page before heading if it and the start of
+// the block after it don't fit on the current one
+func (r *PdfRenderer) keepWithNext(heading *ast.Heading) {
+	if !r.Pagination.KeepWithNext || r.atPageTop() {
+		return
+	}
+	// the line break before the heading, the heading, and so on for the
+	// headings right after it
+	lh := func(s Styler) float64 { return s.Size + s.Spacing }
+	needed := lh(r.cs.peek().textStyle)
+	var next ast.Node = heading
+	for {
+		h, ok := next.(*ast.Heading)
+		if !ok {
+			break
+		}
+		s := r.headingStyler(h.Level)
+		needed += float64(r.textLines(r.headingText(h), s)) * lh(s)
+		next = ast.GetNextNode(h)
+	}
+	// the first lines of the next block, after its line break
+	if next != nil {
+		needed += float64(1+max(r.Pagination.Orphans, 1)) * lh(r.Normal)
+	}
+	r.setStyler(r.cs.peek().textStyle)
+	if needed > r.spaceLeft() {
+		r.tracer("Heading", fmt.Sprintf("kept with next, %v needed", needed))
+		r.addPage()
+	}
+}
+
+// headingStyler returns the style of the headings of level
+func (r *PdfRenderer) headingStyler(level int) Styler {
+	return []Styler{r.H1, r.H2, r.H3, r.H4, r.H5, r.H6}[min(max(level, 1), 6)-1]
+}
+
+// keepLines applies the orphan and widow control to paragraph, about to
+// be written on the line after the cursor: it breaks the page before it,
+// or moves the page break within it up
+func (r *PdfRenderer) keepLines(paragraph *ast.Paragraph) {
+	orphans, widows := max(r.Pagination.Orphans, 1), max(r.Pagination.Widows, 1)
+	if orphans == 1 && widows == 1 || r.atPageTop() {
+		return
+	}
+	s := r.cs.peek().textStyle
+	lh := s.Size + s.Spacing
+	n := r.textLines(ExtractTextFromNode(paragraph), s)
+	r.setStyler(s)
+	// less the line break before the paragraph
+	left := int(math.Floor(r.spaceLeft()/lh+0.01)) - 1
+	if n <= left {
+		return
+	}
+	// the lines on the last page of the paragraph
+	_, ph := r.Pdf.GetPageSize()
+	_, bm := r.Pdf.GetAutoPageBreak()
+	perPage := max(int(math.Floor((ph-bm-r.mtop)/lh+0.01)), 1)
+	last := (n - left) % perPage
+	if last == 0 {
+		last = perPage
+	}
+	shift := max(widows-last, 0)
+	if left-shift < orphans {
+		r.tracer("Paragraph", "moved to the next page")
+		r.addPage()
+		return
+	}
+	if shift > 0 {
+		r.tracer("Paragraph", fmt.Sprintf("page break moved up %v lines", shift))
+		r.pagination.shift = float64(shift) * lh
+		r.Pdf.SetAutoPageBreak(true, r.pageBreakMargin())
+	}
+}
+
+// restorePageBreak puts back the page break trigger moved by keepLines,
+// once the paragraph is written or the page broken
+func (r *PdfRenderer) restorePageBreak() {
+	if r.pagination.shift == 0 {
+		return
+	}
+	r.pagination.shift = 0
+	r.Pdf.SetAutoPageBreak(true, r.pageBreakMargin())
+}
+
+// pageBreakMargin is the distance of the page break trigger from the
+// bottom of the page: the margin, the room reserved for footnotes and
+// the lines a page break was moved up by keepLines
+func (r *PdfRenderer) pageBreakMargin() float64 {
+	return r.mbottom + r.fn.reserved + r.pagination.shift
+}
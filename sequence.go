@@ -0,0 +1,287 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+var (
+	// Alice->>Bob: text, with -> -->> -x -) and their dashed forms
+	seqMessageRe = regexp.MustCompile(`^(.+?)\s*(--?>>|--?>|--?x|--?\))\s*[+-]?\s*(.+?)\s*:\s*(.*)$`)
+	// Note over Alice,Bob: text
+	seqNoteRe = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+([^,:]+?)\s*(?:,\s*([^:]+?))?\s*:\s*(.*)$`)
+)
+
+type seqParticipant struct {
+	label string
+	x, w  float64
+}
+
+type seqEvent struct {
+	note     bool
+	from, to int
+	text     string
+	dashed   bool
+	arrow    bool
+	place    string // of a note: "left of", "right of" or "over"
+	y        float64
+	// extent of a note
+	x1, x2 float64
+}
+
+// sequence is a sequence diagram: participants side by side with their
+// lifelines, and messages and notes from top to bottom
+type sequence struct {
+	participants []*seqParticipant
+	ids          map[string]int
+	events       []*seqEvent
+	style        Styler
+	boxHeight    float64
+	width        float64
+	height       float64
+}
+
+func (s *sequence) participant(id string) int {
+	if i, ok := s.ids[id]; ok {
+		return i
+	}
+	s.ids[id] = len(s.participants)
+	s.participants = append(s.participants, &seqParticipant{label: id})
+	return len(s.participants) - 1
+}
+
+func parseMermaidSequence(r *PdfRenderer, lines []string) (Diagram, error) {
+	s := &sequence{ids: map[string]int{}, style: r.Diagram}
+	for _, l := range lines {
+		f := strings.Fields(l)
+		switch f[0] {
+		case "participant", "actor":
+			if len(f) < 2 {
+				return nil, fmt.Errorf("%v without a name", f[0])
+			}
+			id, label, found := strings.Cut(strings.TrimSpace(l[len(f[0]):]), " as ")
+			p := s.participants[s.participant(strings.TrimSpace(id))]
+			if found {
+				p.label = strings.TrimSpace(label)
+			}
+			continue
+		case "autonumber", "activate", "deactivate", "title", "loop", "alt", "else", "opt",
+			"par", "and", "critical", "break", "rect", "end", "box":
+			// activations and blocks are not drawn
+			continue
+		}
+		if m := seqNoteRe.FindStringSubmatch(l); m != nil {
+			e := &seqEvent{note: true, place: strings.ToLower(m[1]), text: m[4]}
+			e.from = s.participant(m[2])
+			e.to = e.from
+			if m[3] != "" {
+				e.to = s.participant(m[3])
+			}
+			s.events = append(s.events, e)
+			continue
+		}
+		m := seqMessageRe.FindStringSubmatch(l)
+		if m == nil {
+			return nil, fmt.Errorf("cannot parse %q", l)
+		}
+		s.events = append(s.events, &seqEvent{
+			from:   s.participant(m[1]),
+			to:     s.participant(m[3]),
+			text:   m[4],
+			dashed: strings.HasPrefix(m[2], "--"),
+			arrow:  m[2] != "->" && m[2] != "-->",
+		})
+	}
+	if len(s.participants) == 0 {
+		return nil, fmt.Errorf("no participants")
+	}
+	s.layout(r.Pdf)
+	return s, nil
+}
+
+func (s *sequence) lineHeight() float64 {
+	return 1.2 * s.style.Size
+}
+
+// layout spaces the participants so that the messages between them fit,
+// then stacks the messages and notes
+func (s *sequence) layout(pdf *fpdf.Fpdf) {
+	pdf.SetFont(s.style.Font, "", s.style.Size)
+	size, lh := s.style.Size, s.lineHeight()
+	for _, p := range s.participants {
+		p.w = pdf.GetStringWidth(p.label) + 2*size
+	}
+	// distance needed between the lifelines of neighbours
+	gaps := make([]float64, len(s.participants))
+	for i := 1; i < len(s.participants); i++ {
+		gaps[i] = (s.participants[i-1].w+s.participants[i].w)/2 + 2*size
+	}
+	need := func(from, to int, w float64) {
+		if from > to {
+			from, to = to, from
+		}
+		have := 0.0
+		for i := from + 1; i <= to; i++ {
+			have += gaps[i]
+		}
+		if w > have {
+			gaps[to] += w - have
+		}
+	}
+	right := 0.0 // room needed on the right of the last lifeline
+	for _, e := range s.events {
+		w := pdf.GetStringWidth(e.text) + 2*size
+		switch {
+		case e.note && e.place == "over":
+			need(e.from, e.to, w-size)
+		case e.note && e.place == "right of" || !e.note && e.from == e.to:
+			if e.from+1 < len(s.participants) {
+				need(e.from, e.from+1, w+size)
+			} else {
+				right = math.Max(right, w+size)
+			}
+		case e.note:
+			if e.from > 0 {
+				need(e.from-1, e.from, w+size)
+			}
+		default:
+			need(e.from, e.to, w)
+		}
+	}
+	x := 0.0
+	for i, p := range s.participants {
+		x += gaps[i]
+		p.x = x
+	}
+
+	s.boxHeight = lh + size
+	y := s.boxHeight + lh
+	for _, e := range s.events {
+		w := pdf.GetStringWidth(e.text) + size
+		switch {
+		case e.note:
+			a, b := s.participants[e.from].x, s.participants[e.to].x
+			switch e.place {
+			case "left of":
+				e.x1, e.x2 = a-size/2-w, a-size/2
+			case "right of":
+				e.x1, e.x2 = a+size/2, a+size/2+w
+			default:
+				c := (a + b) / 2
+				half := math.Max(w, math.Abs(b-a)+size) / 2
+				e.x1, e.x2 = c-half, c+half
+			}
+			e.y = y
+			y += lh + size + lh/2
+		case e.from == e.to:
+			e.y = y + lh
+			y += 2*lh + size
+		default:
+			e.y = y + lh
+			y += 2 * lh
+		}
+	}
+	s.height = y + lh/2 + s.boxHeight
+
+	// shift everything right of the leftmost box or note
+	minX := math.Inf(1)
+	maxX := s.participants[len(s.participants)-1].x + right
+	for _, p := range s.participants {
+		minX = math.Min(minX, p.x-p.w/2)
+		maxX = math.Max(maxX, p.x+p.w/2)
+	}
+	for _, e := range s.events {
+		if e.note {
+			minX, maxX = math.Min(minX, e.x1), math.Max(maxX, e.x2)
+		}
+	}
+	const border = 2
+	for _, p := range s.participants {
+		p.x += border - minX
+	}
+	for _, e := range s.events {
+		e.x1 += border - minX
+		e.x2 += border - minX
+	}
+	s.width = maxX - minX + 2*border
+	s.height += 2 * border
+}
+
+func (s *sequence) Size() (w, h float64) {
+	return s.width, s.height
+}
+
+func (s *sequence) Draw(pdf *fpdf.Fpdf, x, y float64) {
+	tc, fc := s.style.TextColor, s.style.FillColor
+	size, lh := s.style.Size, s.lineHeight()
+	pdf.SetDrawColor(tc.Red, tc.Green, tc.Blue)
+	pdf.SetTextColor(tc.Red, tc.Green, tc.Blue)
+	pdf.SetFont(s.style.Font, "", s.style.Size)
+	pdf.SetLineWidth(0.75)
+	y += 2
+	bottom := y + s.height - 4 - s.boxHeight
+	text := func(cx, cy float64, t string) {
+		pdf.Text(cx-pdf.GetStringWidth(t)/2, cy+0.3*size, t)
+	}
+
+	// lifelines, then the participant boxes at both ends
+	pdf.SetDashPattern([]float64{3, 2}, 0)
+	for _, p := range s.participants {
+		pdf.Line(x+p.x, y+s.boxHeight, x+p.x, bottom)
+	}
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetFillColor(fc.Red, fc.Green, fc.Blue)
+	for _, p := range s.participants {
+		for _, top := range []float64{y, bottom} {
+			pdf.Rect(x+p.x-p.w/2, top, p.w, s.boxHeight, "FD")
+			text(x+p.x, top+s.boxHeight/2, p.label)
+		}
+	}
+
+	for _, e := range s.events {
+		ey := y + e.y
+		if e.note {
+			pdf.SetFillColor(fc.Red, fc.Green, fc.Blue)
+			pdf.Rect(x+e.x1, ey, e.x2-e.x1, lh+size/2, "FD")
+			text(x+(e.x1+e.x2)/2, ey+(lh+size/2)/2, e.text)
+			continue
+		}
+		if e.dashed {
+			pdf.SetDashPattern([]float64{3, 2}, 0)
+		}
+		x1, x2 := x+s.participants[e.from].x, x+s.participants[e.to].x
+		pdf.SetFillColor(tc.Red, tc.Green, tc.Blue)
+		if e.from == e.to {
+			// a message to self loops on the right of the lifeline
+			loop := 2 * size
+			pdf.Line(x1, ey, x1+loop, ey)
+			pdf.Line(x1+loop, ey, x1+loop, ey+size)
+			pdf.Line(x1+loop, ey+size, x1, ey+size)
+			pdf.SetDashPattern([]float64{}, 0)
+			if e.arrow {
+				drawArrowHead(pdf, x1+loop, ey+size, x1, ey+size, 0.6*size)
+			}
+			pdf.Text(x1+loop+size/2, ey+size/2+0.3*size, e.text)
+			continue
+		}
+		pdf.Line(x1, ey, x2, ey)
+		pdf.SetDashPattern([]float64{}, 0)
+		if e.arrow {
+			drawArrowHead(pdf, x1, ey, x2, ey, 0.6*size)
+		}
+		text((x1+x2)/2, ey-lh/2-1, e.text)
+	}
+}
@@ -0,0 +1,588 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// tableRun is a piece of cell text sharing a single style
+type tableRun struct {
+	text        string
+	style       Styler
+	destination string // links only
+	fill        bool   // code spans are drawn on their fill colour
+	// footnote references are drawn raised, linked to their note
+	footnote *cellFootnote
+	// inline math is drawn typeset; text holds the TeX
+	math *mathBox
+}
+
+// atomic tells whether the run is laid out as a single word, and kept
+// apart from the runs around it
+func (run tableRun) atomic() bool {
+	return run.footnote != nil || run.math != nil
+}
+
+// cellFootnote is a footnote referenced from a cell
+type cellFootnote struct {
+	link int
+	// text of the note, queued for the bottom of the page when the cell
+	// is drawn; empty for endnotes
+	text string
+}
+
+// tableCell is a cell of the row being laid out
+type tableCell struct {
+	runs   []tableRun
+	style  Styler // the header or body style; used for the fill colour
+	header bool
+	align  string // "L", "C" or "R"
+}
+
+// lineSegment is the part of a run which falls on a single line of a cell
+type lineSegment struct {
+	tableRun
+	width float64
+}
+
+type cellLine struct {
+	segments []lineSegment
+	width    float64
+	height   float64
+}
+
+// cellAlign maps the alignment of the delimiter row onto the fpdf
+// alignment string; header cells are centered unless told otherwise
+func cellAlign(node ast.TableCell) string {
+	switch node.Align {
+	case ast.TableAlignmentLeft:
+		return "L"
+	case ast.TableAlignmentRight:
+		return "R"
+	case ast.TableAlignmentCenter:
+		return "C"
+	}
+	if node.IsHeader {
+		return "C"
+	}
+	return "L"
+}
+
+// addCellRun appends a run to the cell being read, merging it with
+// the previous run if they look the same
+func (r *PdfRenderer) addCellRun(run tableRun) {
+	n := len(r.tbl.runs)
+	if n > 0 {
+		last := &r.tbl.runs[n-1]
+		if last.style == run.style && last.destination == run.destination && last.fill == run.fill &&
+			!last.atomic() && !run.atomic() {
+			last.text += run.text
+			return
+		}
+	}
+	r.tbl.runs = append(r.tbl.runs, run)
+}
+
+// tablePadding is the horizontal space between the cell border and its text
+func (r *PdfRenderer) tablePadding() float64 {
+	return r.em / 2
+}
+
+// Parses all tables and records, for each column, the width of its longest
+// text (natural width) and of its longest word (minimal width)
+func setColumnWidths(doc ast.Node, r *PdfRenderer) {
+	// tables may be measured separately, e.g. those made from csv blocks
+	if r.ColumnWidths == nil {
+		r.ColumnWidths = map[ast.Node][]float64{}
+	}
+	if r.columnMinWidths == nil {
+		r.columnMinWidths = map[ast.Node][]float64{}
+	}
+	var lengths, mins []float64
+	cellnum := 0
+	textlength, wordlength := 0.0, 0.0
+	// the style of the text being measured, as processText would set it
+	style, cellStyle := r.TBody, r.TBody
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.Table:
+			if entering {
+				lengths, mins = []float64{}, []float64{}
+			} else {
+				r.ColumnWidths[node] = lengths
+				r.columnMinWidths[node] = mins
+			}
+		case *ast.TableRow:
+			if entering {
+				cellnum = 0
+			}
+		case *ast.TableCell:
+			if entering {
+				cellStyle = r.TBody
+				if n.IsHeader {
+					cellStyle = r.THeader
+				}
+				style = cellStyle
+				if cellnum >= len(lengths) {
+					lengths = append(lengths, 0)
+					mins = append(mins, 0)
+				}
+			} else {
+				padding := 2 * r.tablePadding()
+				lengths[cellnum] = max(lengths[cellnum], textlength+padding)
+				mins[cellnum] = max(mins[cellnum], wordlength+padding)
+				textlength, wordlength = 0, 0
+				cellnum++
+			}
+		case *ast.Emph:
+			style.Style = toggleStyle(style.Style, "i", entering)
+		case *ast.Strong:
+			style.Style = toggleStyle(style.Style, "b", entering)
+		case *ast.Del:
+			style.Style = toggleStyle(style.Style, "s", entering)
+		case *ast.Link:
+			if entering && n.NoteID != 0 && cellnum < len(lengths) {
+				// a footnote reference, written smaller
+				r.setStyler(style)
+				textlength += r.Pdf.GetStringWidth(strconv.Itoa(n.NoteID)) * footnoteScale
+			}
+			if entering {
+				style = r.Link
+			} else {
+				style = cellStyle
+			}
+		case *ast.Math:
+			if entering && cellnum < len(lengths) {
+				box, _ := r.typesetMath(string(n.Literal), style.Size, false)
+				textlength += box.width
+				wordlength = max(wordlength, box.width)
+			}
+		case *ast.Text, *ast.Code:
+			if entering && cellnum < len(lengths) {
+				measured := style
+				if _, ok := node.(*ast.Code); ok {
+					measured = r.Backtick
+				}
+				r.setStyler(measured)
+				literal := string(node.AsLeaf().Literal)
+				textlength += r.Pdf.GetStringWidth(literal)
+				for _, word := range strings.Fields(literal) {
+					wordlength = max(wordlength, r.Pdf.GetStringWidth(word))
+				}
+			}
+		}
+		return ast.GoToNext
+	})
+}
+
+// toggleStyle adds the fpdf style flag when entering a node and
+// removes it when leaving
+func toggleStyle(style, flag string, entering bool) string {
+	if entering {
+		return style + flag
+	}
+	return strings.ReplaceAll(style, flag, "")
+}
+
+// fitColumnWidths caps the total width of the table to the printable area,
+// distributing the available width proportionally to the natural widths
+// while trying to keep each column at least as wide as its longest word.
+func fitColumnWidths(natural, minimal []float64, available float64) []float64 {
+	total := 0.0
+	for _, w := range natural {
+		total += w
+	}
+	widths := make([]float64, len(natural))
+	if total <= available {
+		copy(widths, natural)
+		return widths
+	}
+	// columns whose proportional share is below their minimal width get the
+	// minimal width; the rest is shared by the others
+	fixed := make([]bool, len(natural))
+	for {
+		remaining, share := available, 0.0
+		for i, w := range natural {
+			if fixed[i] {
+				remaining -= minimal[i]
+			} else {
+				share += w
+			}
+		}
+		changed := false
+		for i, w := range natural {
+			if !fixed[i] && share > 0 && remaining*w/share < minimal[i] {
+				fixed[i] = true
+				changed = true
+			}
+		}
+		if !changed {
+			for i, w := range natural {
+				if fixed[i] {
+					widths[i] = minimal[i]
+				} else {
+					widths[i] = remaining * w / share
+				}
+			}
+			break
+		}
+	}
+	// even the minimal widths don't fit; narrow the widest columns
+	// down to a common width, leaving the others alone
+	sum := 0.0
+	for _, w := range widths {
+		sum += w
+	}
+	if sum > available {
+		lo, hi := 0.0, available
+		for range 50 {
+			limit := (lo + hi) / 2
+			sum = 0
+			for _, w := range widths {
+				sum += min(w, limit)
+			}
+			if sum > available {
+				hi = limit
+			} else {
+				lo = limit
+			}
+		}
+		for i := range widths {
+			widths[i] = min(widths[i], lo)
+		}
+	}
+	return widths
+}
+
+// layoutCell breaks the runs of a cell into lines no wider than width
+func (r *PdfRenderer) layoutCell(runs []tableRun, width float64) []cellLine {
+	lines := []cellLine{{}}
+	for _, run := range runs {
+		r.setStyler(run.style)
+		lh := run.style.Size + run.style.Spacing
+		if run.atomic() {
+			// a footnote label, written smaller, or typeset math
+			line := &lines[len(lines)-1]
+			ww := r.Pdf.GetStringWidth(run.text) * footnoteScale
+			if run.math != nil {
+				ww = run.math.width
+			}
+			if line.width+ww > width+0.01 && line.width > 0 {
+				lines = append(lines, cellLine{})
+				line = &lines[len(lines)-1]
+			}
+			line.add(run, run.text, ww, lh)
+			continue
+		}
+		for _, word := range strings.SplitAfter(run.text, " ") {
+			if word == "" {
+				continue
+			}
+			line := &lines[len(lines)-1]
+			ww := r.Pdf.GetStringWidth(strings.TrimRight(word, " "))
+			if line.width+ww > width+0.01 && line.width > 0 {
+				lines = append(lines, cellLine{})
+				line = &lines[len(lines)-1]
+				word = strings.TrimLeft(word, " ")
+				if word == "" {
+					continue
+				}
+			}
+			// a word wider than the cell is broken up
+			for line.width == 0 && r.Pdf.GetStringWidth(strings.TrimRight(word, " ")) > width+0.01 && utf8.RuneCountInString(word) > 1 {
+				n := len(word)
+				for n > 1 && r.Pdf.GetStringWidth(word[:n]) > width {
+					_, size := utf8.DecodeLastRuneInString(word[:n])
+					n -= size
+				}
+				line.add(run, word[:n], r.Pdf.GetStringWidth(word[:n]), lh)
+				lines = append(lines, cellLine{})
+				line = &lines[len(lines)-1]
+				word = word[n:]
+			}
+			line.add(run, word, r.Pdf.GetStringWidth(word), lh)
+		}
+	}
+	for i := range lines {
+		lines[i].trim(r)
+	}
+	return lines
+}
+
+// add appends text of run to the line, merging it with the last segment
+// if they share the run
+func (l *cellLine) add(run tableRun, text string, width, height float64) {
+	l.height = max(l.height, height)
+	l.width += width
+	n := len(l.segments)
+	if n > 0 && l.segments[n-1].style == run.style && l.segments[n-1].destination == run.destination &&
+		l.segments[n-1].fill == run.fill && !l.segments[n-1].atomic() && !run.atomic() {
+		l.segments[n-1].text += text
+		l.segments[n-1].width += width
+		return
+	}
+	seg := lineSegment{tableRun: run, width: width}
+	seg.text = text
+	l.segments = append(l.segments, seg)
+}
+
+// trim removes the trailing space of the line
+func (l *cellLine) trim(r *PdfRenderer) {
+	n := len(l.segments)
+	if n == 0 {
+		return
+	}
+	seg := &l.segments[n-1]
+	trimmed := strings.TrimRight(seg.text, " ")
+	if trimmed != seg.text && !seg.atomic() {
+		r.setStyler(seg.style)
+		w := r.Pdf.GetStringWidth(trimmed)
+		l.width -= seg.width - w
+		seg.width = w
+		seg.text = trimmed
+	}
+}
+
+// layoutRow returns the lines of each cell and the height of the row,
+// that of its tallest cell
+func (r *PdfRenderer) layoutRow(cells []tableCell) ([][]cellLine, float64) {
+	layout := make([][]cellLine, len(cells))
+	height := 0.0
+	for i, cell := range cells {
+		w := r.tbl.cellwidths[min(i, len(r.tbl.cellwidths)-1)]
+		layout[i] = r.layoutCell(cell.runs, w-2*r.tablePadding())
+		h := 0.0
+		for j := range layout[i] {
+			if layout[i][j].height == 0 {
+				// an empty cell
+				layout[i][j].height = cell.style.Size + cell.style.Spacing
+			}
+			h += layout[i][j].height
+		}
+		height = max(height, h)
+	}
+	return layout, height
+}
+
+// tableWidth is the sum of the column widths
+func (r *PdfRenderer) tableWidth() float64 {
+	w := 0.0
+	for _, cw := range r.tbl.cellwidths {
+		w += cw
+	}
+	return w
+}
+
+// placeTableRow draws a row, first moving the table to a new page if
+// the row doesn't fit on the current one; a row taller than a page is
+// split between the lines of its cells. Header rows are held back
+// until the first body row so that they can be kept together, and are
+// repeated at the top of every page the table continues on.
+func (r *PdfRenderer) placeTableRow(cells []tableCell) {
+	if len(r.tbl.cellwidths) == 0 && len(cells) > 0 {
+		// a table which wasn't measured, e.g. one rendered by a
+		// NodeRenderer: the columns share the width of the page
+		pw, _ := r.Pdf.GetPageSize()
+		w := (pw - r.tbl.left - r.mright) / float64(len(cells))
+		for range cells {
+			r.tbl.cellwidths = append(r.tbl.cellwidths, w)
+		}
+	}
+	if len(cells) > 0 && cells[0].header {
+		r.tbl.header = append(r.tbl.header, cells)
+		r.tbl.headerPending = true
+		return
+	}
+	layout, height := r.layoutRow(cells)
+	needed := height
+	if r.tbl.headerPending {
+		for _, header := range r.tbl.header {
+			_, h := r.layoutRow(header)
+			needed += h
+		}
+	}
+	_, bm := r.Pdf.GetAutoPageBreak()
+	_, ph := r.Pdf.GetPageSize()
+	y := r.Pdf.GetY()
+	// a row taller than a page is split from here on
+	if y+needed > ph-bm && y > r.mtop+0.01 && needed <= ph-bm-r.mtop {
+		r.tracer("... table row", "moving to a new page")
+		started := !r.tbl.headerPending
+		if started {
+			// close the border of the part already drawn
+			r.Pdf.Line(r.tbl.left, y, r.tbl.left+r.tableWidth(), y)
+		}
+		r.addPage()
+		r.Pdf.SetX(r.tbl.left)
+		if started {
+			r.drawContinuedCaption()
+		}
+		r.tbl.fill = false
+		r.tbl.headerPending = len(r.tbl.header) > 0
+	}
+	r.drawPendingHeader()
+	for {
+		_, bm := r.Pdf.GetAutoPageBreak()
+		room := ph - bm - r.Pdf.GetY()
+		if height <= room+0.01 {
+			break
+		}
+		r.tracer("... table row", "split across pages")
+		part, rest := splitCellLines(layout, room)
+		r.drawRowLines(cells, part, rowHeight(part))
+		y := r.Pdf.GetY()
+		r.Pdf.Line(r.tbl.left, y, r.tbl.left+r.tableWidth(), y)
+		r.addPage()
+		r.Pdf.SetX(r.tbl.left)
+		r.drawContinuedCaption()
+		r.tbl.headerPending = len(r.tbl.header) > 0
+		r.drawPendingHeader()
+		layout, height = rest, rowHeight(rest)
+	}
+	r.drawRowLines(cells, layout, height)
+	r.tbl.fill = !r.tbl.fill
+}
+
+// splitCellLines splits the lines of each cell of a row into those
+// fitting in height and the rest. At least one line of a cell is taken
+// when none of the cells would have any.
+func splitCellLines(layout [][]cellLine, height float64) (part, rest [][]cellLine) {
+	part, rest = make([][]cellLine, len(layout)), make([][]cellLine, len(layout))
+	taken := false
+	for i, lines := range layout {
+		n, h := 0, 0.0
+		for n < len(lines) && h+lines[n].height <= height+0.01 {
+			h += lines[n].height
+			n++
+		}
+		part[i], rest[i] = lines[:n], lines[n:]
+		taken = taken || n > 0
+	}
+	if !taken {
+		for i, lines := range layout {
+			n := min(len(lines), 1)
+			part[i], rest[i] = lines[:n], lines[n:]
+		}
+	}
+	return part, rest
+}
+
+// rowHeight returns the height of the tallest cell of a row
+func rowHeight(layout [][]cellLine) float64 {
+	height := 0.0
+	for _, lines := range layout {
+		h := 0.0
+		for _, line := range lines {
+			h += line.height
+		}
+		height = max(height, h)
+	}
+	return height
+}
+
+// drawPendingHeader draws the header rows if they haven't been drawn
+// on the current page yet
+func (r *PdfRenderer) drawPendingHeader() {
+	if !r.tbl.headerPending {
+		return
+	}
+	r.tbl.headerPending = false
+	for _, header := range r.tbl.header {
+		r.drawTableRow(header)
+	}
+}
+
+// drawContinuedCaption writes TableContinuedCaption, if set, above
+// the part of a table carried over to a new page
+func (r *PdfRenderer) drawContinuedCaption() {
+	if r.TableContinuedCaption == "" {
+		return
+	}
+	style := r.TBody
+	style.Style = "I"
+	r.setStyler(style)
+	r.Pdf.CellFormat(r.tableWidth(), style.Size+style.Spacing,
+		r.TableContinuedCaption, "", 1, "L", false, 0, "")
+	r.Pdf.SetX(r.tbl.left)
+}
+
+// drawTableRow draws the cells of a row; the height of the row is
+// that of its tallest cell
+func (r *PdfRenderer) drawTableRow(cells []tableCell) {
+	layout, height := r.layoutRow(cells)
+	r.drawRowLines(cells, layout, height)
+	r.tbl.fill = !r.tbl.fill
+}
+
+// drawRowLines draws the cells of a row, holding the lines of layout,
+// with their background and borders
+func (r *PdfRenderer) drawRowLines(cells []tableCell, layout [][]cellLine, height float64) {
+	x, y := r.tbl.left, r.Pdf.GetY()
+	r.tracer("... table row", fmt.Sprintf("cells=%v, height=%v", len(cells), height))
+	for i, cell := range cells {
+		w := r.tbl.cellwidths[min(i, len(r.tbl.cellwidths)-1)]
+		if cell.header || r.tbl.fill {
+			fc := cell.style.FillColor
+			r.Pdf.SetFillColor(fc.Red, fc.Green, fc.Blue)
+			r.Pdf.Rect(x, y, w, height, "F")
+		}
+		if cell.header {
+			r.Pdf.Rect(x, y, w, height, "D")
+		} else {
+			r.Pdf.Line(x, y, x, y+height)
+			r.Pdf.Line(x+w, y, x+w, y+height)
+		}
+		r.drawCellLines(layout[i], x, y, w, cell)
+		x += w
+	}
+	r.Pdf.SetXY(r.tbl.left, y+height)
+}
+
+// drawCellLines writes the lines of a cell, starting at the top of the cell
+func (r *PdfRenderer) drawCellLines(lines []cellLine, x, y, w float64, cell tableCell) {
+	cm := r.Pdf.GetCellMargin()
+	r.Pdf.SetCellMargin(0)
+	defer r.Pdf.SetCellMargin(cm)
+	for _, line := range lines {
+		lx := x + r.tablePadding()
+		switch cell.align {
+		case "C":
+			lx = x + (w-line.width)/2
+		case "R":
+			lx = x + w - r.tablePadding() - line.width
+		}
+		for _, seg := range line.segments {
+			r.setStyler(seg.style)
+			r.Pdf.SetXY(lx, y)
+			if seg.math != nil {
+				// on the baseline of the text written with CellFormat
+				r.drawMath(seg.math, lx, y+line.height/2+0.3*seg.style.Size)
+			} else if fn := seg.footnote; fn != nil {
+				r.Pdf.SubWrite(line.height, seg.text, seg.style.Size*footnoteScale,
+					seg.style.Size*(1-footnoteScale), fn.link, "")
+				if fn.text != "" {
+					r.addFootnote(seg.text, fn.link, fn.text)
+				}
+			} else if seg.destination != "" {
+				r.writeLinkString(line.height, seg.text, seg.destination)
+			} else {
+				r.Pdf.CellFormat(seg.width, line.height, seg.text, "", 0, "L", seg.fill, 0, "")
+			}
+			lx += seg.width
+		}
+		y += line.height
+	}
+}
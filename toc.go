@@ -0,0 +1,238 @@
+/*
+ * Markdown to PDF Converter
+ * Available at http://github.com/solworktech/md2pdf
+ *
+ * Copyright © Cecil New <cecil.new@gmail.com>, Jesse Portnoy <jesse@packman.io>.
+ * Distributed under the MIT License.
+ * See README.md for details.
+ */
+
+package mdtopdf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// TOCOptions configures the table of contents
+type TOCOptions struct {
+	// Title is written above the entries, "Table of Contents" if empty;
+	// PagesAlias in it is replaced with the number of pages
+	Title string
+	// MaxLevel is the deepest heading level listed, 6 if 0
+	MaxLevel int
+}
+
+// tocMarker is where the table of contents goes: in place of a [TOC]
+// paragraph or, without one, at the start of the document
+type tocMarker struct {
+	ast.Leaf
+	// start a new page after the table, as it precedes the document
+	newPage bool
+}
+
+// tocEntry is a heading listed in the table of contents
+type tocEntry struct {
+	level int
+	title string
+	link  int
+	// page is the label of the page the heading is on, once placed
+	page string
+}
+
+// tocSlot is where the page number of an entry is written
+type tocSlot struct {
+	entry *tocEntry
+	page  int
+	// end of the entry title, and top of its last line
+	x, y float64
+}
+
+type tocState struct {
+	enabled bool
+	opts    TOCOptions
+	entries []*tocEntry
+	// entries keyed by their heading
+	byHeading map[*ast.Heading]*tocEntry
+	slots     []tocSlot
+	// title is where a title holding PagesAlias is written, once the
+	// number of pages is known
+	title *tocTitle
+}
+
+// tocTitle is a title left to finishTOC
+type tocTitle struct {
+	text string
+	page int
+	x, y float64
+}
+
+// prepareTOC lists the headings of doc and puts the table of contents in
+// place of the [TOC] paragraphs, or at the start of doc (or of its front
+// matter). The entries are keyed by their heading, so that headings
+// without an ID, or sharing one, are listed as well and doc is left as
+// it is.
+func (r *PdfRenderer) prepareTOC(doc ast.Node) {
+	r.toc.entries = nil
+	r.toc.byHeading = make(map[*ast.Heading]*tocEntry)
+	r.toc.slots = nil
+	r.toc.title = nil
+	maxLevel := r.toc.opts.MaxLevel
+	if maxLevel == 0 {
+		maxLevel = 6
+	}
+
+	var markers []ast.Node
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Paragraph:
+			if strings.TrimSpace(ExtractTextFromNode(node)) == "[TOC]" {
+				markers = append(markers, node)
+			}
+			return ast.SkipChildren
+		case *ast.Heading:
+			if node.Level > maxLevel || node.IsTitleblock {
+				return ast.SkipChildren
+			}
+			entry := &tocEntry{level: node.Level, title: r.headingText(node), link: r.Pdf.AddLink()}
+			r.toc.entries = append(r.toc.entries, entry)
+			r.toc.byHeading[node] = entry
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+
+	if len(markers) == 0 {
+		// at the start of the front matter, if the document has one
+		children := doc.GetChildren()
+		at := 0
+		if len(children) > 0 {
+			if m, ok := children[0].(*ast.DocumentMatter); ok && m.Matter == ast.DocumentMatterFront {
+				at = 1
+			}
+		}
+		marker := &tocMarker{newPage: true}
+		marker.Parent = doc
+		doc.SetChildren(append(children[:at:at], append([]ast.Node{marker}, children[at:]...)...))
+		return
+	}
+	for _, m := range markers {
+		marker := &tocMarker{}
+		parent := m.GetParent()
+		marker.Parent = parent
+		children := parent.GetChildren()
+		for i, c := range children {
+			if c == m {
+				children[i] = marker
+			}
+		}
+	}
+}
+
+// processTOC writes the titles of the entries; their page numbers are
+// filled in by finishTOC once the headings have been placed
+func (r *PdfRenderer) processTOC(node *tocMarker) {
+	r.tracer("TOC", fmt.Sprintf("%d entries", len(r.toc.entries)))
+	first := r.Pdf.PageNo()
+	title := r.toc.opts.Title
+	if title == "" {
+		title = "Table of Contents"
+	}
+	r.cr()
+	r.setStyler(r.H1)
+	if strings.Contains(title, PagesAlias) {
+		r.toc.title = &tocTitle{text: title, page: r.Pdf.PageNo(), x: r.Pdf.GetX(), y: r.Pdf.GetY()}
+	} else {
+		r.write(r.H1, title)
+	}
+	r.cr()
+	r.cr()
+
+	s := r.Normal
+	r.setStyler(s)
+	lh := s.Size + s.Spacing
+	lm, _, _, _ := r.Pdf.GetMargins()
+	pw, _ := r.Pdf.GetPageSize()
+	// leave room for the dot leaders and page numbers
+	right := pw - r.mright - r.Pdf.GetStringWidth("........0000")
+	minLevel := 6
+	for _, e := range r.toc.entries {
+		minLevel = min(minLevel, e.level)
+	}
+	for _, e := range r.toc.entries {
+		x := lm + float64(e.level-minLevel)*r.IndentValue
+		lines := r.Pdf.SplitText(e.title, right-x)
+		for i, line := range lines {
+			r.Pdf.SetX(x)
+			w := r.Pdf.GetStringWidth(line)
+			r.Pdf.CellFormat(w, lh, line, "", 0, "L", false, e.link, "")
+			if i == len(lines)-1 {
+				r.toc.slots = append(r.toc.slots, tocSlot{entry: e, page: r.Pdf.PageNo(), x: x + w, y: r.Pdf.GetY()})
+			}
+			r.Pdf.Ln(lh)
+		}
+	}
+	if r.pages.tocPages == nil {
+		r.pages.tocPages = make(map[int]bool)
+	}
+	for page := first; page <= r.Pdf.PageNo(); page++ {
+		r.pages.tocPages[page] = true
+	}
+	if node.newPage {
+		r.addPage()
+	}
+}
+
+// tocHeading links the table of contents entry of heading, if any, to
+// the current position
+func (r *PdfRenderer) tocHeading(heading *ast.Heading) {
+	if e, ok := r.toc.byHeading[heading]; ok && e.page == "" {
+		r.Pdf.SetLink(e.link, -1, -1)
+		e.page = r.pageLabel(r.Pdf.PageNo())
+	}
+}
+
+// finishTOC goes back to the pages of the table of contents to write the
+// page numbers, right aligned after dot leaders, and the title if it
+// holds PagesAlias
+func (r *PdfRenderer) finishTOC() {
+	if len(r.toc.slots) == 0 && r.toc.title == nil {
+		return
+	}
+	last := r.Pdf.PageNo()
+	if t := r.toc.title; t != nil {
+		_, _, count := r.pageNumber(last)
+		r.Pdf.SetPage(t.page)
+		r.Pdf.SetXY(t.x, t.y)
+		r.setStyler(r.H1)
+		r.write(r.H1, strings.ReplaceAll(t.text, PagesAlias, strconv.Itoa(count)))
+	}
+	s := r.Normal
+	lh := s.Size + s.Spacing
+	pw, _ := r.Pdf.GetPageSize()
+	for _, slot := range r.toc.slots {
+		if slot.entry.page == "" {
+			continue
+		}
+		r.Pdf.SetPage(slot.page)
+		r.setStyler(s)
+		baseline := slot.y + lh/2 + 0.3*s.Size
+		nw := r.Pdf.GetStringWidth(slot.entry.page)
+		nx := pw - r.mright - nw
+		r.Pdf.Text(nx, baseline, slot.entry.page)
+		gap := r.Pdf.GetStringWidth(" ")
+		dot := r.Pdf.GetStringWidth(".")
+		if n := int(math.Floor((nx - slot.x - 2*gap) / dot)); n > 0 {
+			r.Pdf.Text(nx-gap-float64(n)*dot, baseline, strings.Repeat(".", n))
+		}
+		r.Pdf.Link(slot.x, slot.y, nx+nw-slot.x, lh, slot.entry.link)
+	}
+	r.Pdf.SetPage(last)
+}